@@ -18,7 +18,10 @@ package v1alpha1
 
 import (
 	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // CarsSpec defines the desired state of Cars
@@ -29,15 +32,431 @@ type CarsSpec struct {
 	StorageVolume    string                         `json:"storageVolume,omitempty"`
 	Domain           string                         `json:"domain,omitempty"`
 	ClusterIssuer    string                         `json:"clusterIssuer,omitempty"`
+	// Storage configures the lifecycle of the mysql data
+	Storage CarsStorageSpec `json:"storage,omitempty"`
+	// Replicas is the number of cars pods. Defaults to 1 and is left to the autoscaler when autoscaling is enabled
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Autoscaling scales the cars pods with a HorizontalPodAutoscaler
+	// +optional
+	Autoscaling *CarsAutoscalingSpec `json:"autoscaling,omitempty"`
+	// Service configures how the cars API is exposed inside and outside the cluster
+	// +optional
+	Service CarsServiceSpec `json:"service,omitempty"`
+	// Ingress configures the Ingress of the cars API. Domain and ClusterIssuer remain a shorthand for the host
+	// <name>.<domain> and its certificate
+	// +optional
+	Ingress CarsIngressSpec `json:"ingress,omitempty"`
+	// Disruption configures the PodDisruptionBudgets guarding the cars and mysql pods against voluntary evictions
+	// +optional
+	Disruption CarsDisruptionSpec `json:"disruption,omitempty"`
+	// Resources of the cars container, replacing the defaults. Requests must not exceed limits
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
+	// Database configures the mysql database
+	// +optional
+	Database CarsDatabaseSpec `json:"database,omitempty"`
+	// EnvFrom lists the Secrets and ConfigMaps exposed to the cars container, replacing the cars-environment Secret
+	// +optional
+	EnvFrom []v1.EnvFromSource `json:"envFrom,omitempty"`
+	// Env lists literal and valueFrom variables of the cars container. They take precedence over EnvFrom and
+	// over variables of the same name set by the operator
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+	// Config is the cars application configuration, rendered into a ConfigMap mounted into the cars pods
+	// +optional
+	Config CarsConfigSpec `json:"config,omitempty"`
+	// Probes override the default probes of the cars container
+	// +optional
+	Probes CarsProbesSpec `json:"probes,omitempty"`
+	// Network the instance serves. It selects the network keys injected into the cars container from the first
+	// Secret of EnvFrom, which must hold them, and labels the children. That Secret is then no longer exposed as a
+	// whole: only the keys of the network and MYSQL_DATABASE_URL are, further keys have to be listed under Env
+	// +optional
+	Network Network `json:"network,omitempty"`
+	// ImagePullPolicy of the cars and mysql containers. Defaults to Always
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	// +optional
+	ImagePullPolicy v1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// ImagePullSecrets are used to pull the cars and mysql images and to resolve their digests
+	// +optional
+	ImagePullSecrets []v1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// Scheduling constrains the nodes the cars pods run on. Without an affinity the cars pods prefer separate nodes
+	// +optional
+	Scheduling CarsSchedulingSpec `json:"scheduling,omitempty"`
+	// ServiceAccountName references an existing ServiceAccount the cars pods run as. When empty the operator
+	// renders a ServiceAccount with a Role and RoleBinding granting the cars app access to its own namespace
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// PodSecurityContext of the cars pods, replacing the restricted default
+	// +optional
+	PodSecurityContext *v1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+	// SecurityContext of the cars container, replacing the restricted default
+	// +optional
+	SecurityContext *v1.SecurityContext `json:"securityContext,omitempty"`
+	// PodTemplate is a strategic merge patch of the cars pod template, applied on top of what the operator renders.
+	// It adds sidecars, init containers, volumes, labels and annotations, but must not change the selector labels
+	// or remove the cars container. Containers it adds without a security context get the one of the cars container
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	PodTemplate *runtime.RawExtension `json:"podTemplate,omitempty"`
+	// Patches are applied to the rendered children just before they are written, like kustomize patches.
+	// They are applied in order, after the pod template overlays
+	// +optional
+	Patches []CarsPatch `json:"patches,omitempty"`
+	// ResolveImageDigests resolves the image tags to digests on every reconcile and pins the containers to them,
+	// so a pod restart cannot silently change the running version. The digests are recorded in status
+	// +optional
+	ResolveImageDigests bool `json:"resolveImageDigests,omitempty"`
 }
 
+// Network is a BSV network
+// +kubebuilder:validation:Enum=mainnet;testnet
+type Network string
+
+const (
+	// NetworkMainnet is the BSV main network
+	NetworkMainnet Network = "mainnet"
+	// NetworkTestnet is the BSV test network
+	NetworkTestnet Network = "testnet"
+)
+
+// CarsProbesSpec overrides the probes of the cars container. The defaults check the listen port over TCP.
+// A probe without a handler keeps the default handler and only overrides its timings and thresholds
+type CarsProbesSpec struct {
+	// Startup probe, holding off the other probes until the cars app has started
+	// +optional
+	Startup *v1.Probe `json:"startup,omitempty"`
+	// Liveness probe, restarting a hung cars container
+	// +optional
+	Liveness *v1.Probe `json:"liveness,omitempty"`
+	// Readiness probe, keeping the pod out of the Service until it can serve
+	// +optional
+	Readiness *v1.Probe `json:"readiness,omitempty"`
+}
+
+// CarsConfigSpec defines the cars application configuration
+type CarsConfigSpec struct {
+	// LogLevel of the cars app
+	// +kubebuilder:validation:Enum=debug;info;warn;error
+	// +optional
+	LogLevel string `json:"logLevel,omitempty"`
+	// ListenPort is the port the cars API listens on inside the pod. Defaults to 7777
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	ListenPort *int32 `json:"listenPort,omitempty"`
+	// Broadcasters are the endpoints transactions are broadcast to, in order of preference
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Broadcasters []CarsBroadcasterSpec `json:"broadcasters,omitempty"`
+	// Extra holds settings without a typed field. Typed fields take precedence
+	// +optional
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// CarsBroadcasterSpec is an endpoint transactions are broadcast to
+type CarsBroadcasterSpec struct {
+	// Name of the broadcaster
+	Name string `json:"name"`
+	// URL of the broadcaster API
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+}
+
+// CarsSchedulingSpec holds the scheduling fields of a pod template
+type CarsSchedulingSpec struct {
+	// NodeSelector must match the labels of the nodes the pods run on
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations let the pods run on tainted nodes
+	// +optional
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+	// Affinity of the pods, replacing the default
+	// +optional
+	Affinity *v1.Affinity `json:"affinity,omitempty"`
+	// TopologySpreadConstraints spread the pods across topology domains
+	// +optional
+	TopologySpreadConstraints []v1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	// PriorityClassName of the pods
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+}
+
+// CarsDatabaseSpec defines the mysql database
+type CarsDatabaseSpec struct {
+	// PodSecurityContext of the mysql pod and backup job, replacing the restricted default
+	// +optional
+	PodSecurityContext *v1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+	// SecurityContext of the mysql container and backup job, replacing the restricted default
+	// +optional
+	SecurityContext *v1.SecurityContext `json:"securityContext,omitempty"`
+	// PodTemplate is a strategic merge patch of the mysql pod template, applied on top of what the operator renders.
+	// It must not change the selector labels or remove the mysql container. Containers it adds without a security
+	// context get the one of the mysql container
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	PodTemplate *runtime.RawExtension `json:"podTemplate,omitempty"`
+	// Scheduling constrains the nodes the mysql pod runs on
+	// +optional
+	Scheduling CarsSchedulingSpec `json:"scheduling,omitempty"`
+	// Image of the mysql container. Defaults to mysql:8.0
+	// +optional
+	Image string `json:"image,omitempty"`
+	// Resources of the mysql container, replacing the defaults. Requests must not exceed limits
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
+	// EnvFrom lists the Secrets and ConfigMaps exposed to the mysql container and backup job, replacing the
+	// mysql-environment Secret
+	// +optional
+	EnvFrom []v1.EnvFromSource `json:"envFrom,omitempty"`
+	// Env lists literal and valueFrom variables of the mysql container and backup job. They take precedence over
+	// EnvFrom and over variables of the same name set by the operator
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+}
+
+// CarsAutoscalingSpec defines how the cars pods scale horizontally
+// +kubebuilder:validation:XValidation:rule="!has(self.minReplicas) || self.minReplicas <= self.maxReplicas",message="minReplicas must not exceed maxReplicas"
+type CarsAutoscalingSpec struct {
+	// MinReplicas is the lower limit of cars pods. Defaults to 1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// MaxReplicas is the upper limit of cars pods
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+	// TargetCPUUtilizationPercentage is the average CPU utilization to scale at. Defaults to 80 when no target is set
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+	// TargetMemoryUtilizationPercentage is the average memory utilization to scale at
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TargetMemoryUtilizationPercentage *int32 `json:"targetMemoryUtilizationPercentage,omitempty"`
+}
+
+// CarsServiceSpec defines the Service of the cars API
+// +kubebuilder:validation:XValidation:rule="!has(self.loadBalancerSourceRanges) || (has(self.type) && self.type == 'LoadBalancer')",message="loadBalancerSourceRanges requires type LoadBalancer"
+// +kubebuilder:validation:XValidation:rule="!has(self.externalTrafficPolicy) || (has(self.type) && self.type != 'ClusterIP')",message="externalTrafficPolicy requires type NodePort or LoadBalancer"
+type CarsServiceSpec struct {
+	// Type of the Service. ClusterIP, the default, renders a headless Service resolving to the cars pods.
+	// Changing from or to ClusterIP recreates the Service, as a headless Service cannot be converted
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +optional
+	Type v1.ServiceType `json:"type,omitempty"`
+	// Annotations of the Service, e.g. cloud load balancer settings
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// IPFamilyPolicy of the Service. Defaults to SingleStack IPv4, dual stack lets the cluster pick the families
+	// +kubebuilder:validation:Enum=SingleStack;PreferDualStack;RequireDualStack
+	// +optional
+	IPFamilyPolicy *v1.IPFamilyPolicy `json:"ipFamilyPolicy,omitempty"`
+	// LoadBalancerSourceRanges restricts the client CIDRs a LoadBalancer Service accepts
+	// +optional
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+	// ExternalTrafficPolicy of a NodePort or LoadBalancer Service. Local preserves the client address
+	// +kubebuilder:validation:Enum=Cluster;Local
+	// +optional
+	ExternalTrafficPolicy v1.ServiceExternalTrafficPolicy `json:"externalTrafficPolicy,omitempty"`
+}
+
+// CarsIngressSpec defines the Ingress of the cars API. It is rendered when a domain or hosts are set
+type CarsIngressSpec struct {
+	// ClassName of the ingress controller serving the Ingress. Defaults to nginx
+	// +optional
+	ClassName *string `json:"className,omitempty"`
+	// Hosts the cars API is served at, replacing <name>.<domain>. The first one is reported as the URL
+	// +optional
+	Hosts []string `json:"hosts,omitempty"`
+	// Paths routed to the cars API on every host. Defaults to every path
+	// +optional
+	Paths []CarsIngressPath `json:"paths,omitempty"`
+	// Annotations of the Ingress. They take precedence over the cert-manager annotation set from ClusterIssuer
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// TLS configures the certificate of the hosts
+	// +optional
+	TLS CarsIngressTLSSpec `json:"tls,omitempty"`
+}
+
+// CarsIngressPath defines a path routed to the cars API
+type CarsIngressPath struct {
+	// Path matched against the request path
+	// +kubebuilder:validation:Pattern=`^/`
+	Path string `json:"path"`
+	// PathType is how the path is matched. Defaults to Prefix
+	// +kubebuilder:validation:Enum=Exact;Prefix;ImplementationSpecific
+	// +optional
+	PathType *networkingv1.PathType `json:"pathType,omitempty"`
+}
+
+// CarsIngressTLSSpec defines the TLS termination of the Ingress
+type CarsIngressTLSSpec struct {
+	// Disabled serves the hosts over plain HTTP, without requesting a certificate. TLS is only on by default when
+	// ClusterIssuer or SecretName provides the certificate
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+	// SecretName of the certificate. Defaults to <name>-tls, which cert-manager issues when ClusterIssuer is set
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// CarsDisruptionSpec defines the PodDisruptionBudgets of the cars and mysql pods
+type CarsDisruptionSpec struct {
+	// Disabled skips the PodDisruptionBudgets, the ones rendered before are pruned
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+	// Cars is the budget of the cars pods, rendered when more than one of them runs. Defaults to maxUnavailable 1
+	// +optional
+	Cars PodDisruptionSpec `json:"cars,omitempty"`
+	// Database is the budget of the mysql pod, rendered only when set. maxUnavailable 0 makes node drains wait
+	// until the database is moved deliberately
+	// +optional
+	Database PodDisruptionSpec `json:"database,omitempty"`
+}
+
+// PodDisruptionSpec defines how many pods a voluntary disruption may take down
+// +kubebuilder:validation:XValidation:rule="!(has(self.minAvailable) && has(self.maxUnavailable))",message="minAvailable and maxUnavailable are mutually exclusive"
+type PodDisruptionSpec struct {
+	// MinAvailable is the number or percentage of pods that must stay available
+	// +optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+	// MaxUnavailable is the number or percentage of pods that may be unavailable
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// PatchType is the format of a patch
+// +kubebuilder:validation:Enum=JSON6902;Strategic
+type PatchType string
+
+const (
+	// PatchTypeJSON6902 is a list of JSON patch operations as defined by RFC 6902
+	PatchTypeJSON6902 PatchType = "JSON6902"
+	// PatchTypeStrategic is a strategic merge patch, like kubectl patch applies by default
+	PatchTypeStrategic PatchType = "Strategic"
+)
+
+// CarsPatch defines a patch of the rendered children
+type CarsPatch struct {
+	// Target selects the children to patch
+	Target PatchTarget `json:"target"`
+	// Type of the patch. Defaults to Strategic
+	// +kubebuilder:default=Strategic
+	// +optional
+	Type PatchType `json:"type,omitempty"`
+	// Patch is the body of the patch, in JSON or YAML
+	// +kubebuilder:validation:MinLength=1
+	Patch string `json:"patch"`
+}
+
+// PatchTarget selects rendered children by kind and name
+type PatchTarget struct {
+	// Kind of the children, e.g. Service
+	// +kubebuilder:validation:MinLength=1
+	Kind string `json:"kind"`
+	// Name of the child. Every child of the kind is patched when empty
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// RetentionPolicy defines what happens to the mysql data when a Cars instance is deleted
+// +kubebuilder:validation:Enum=Retain;Delete;BackupThenDelete
+type RetentionPolicy string
+
+const (
+	// RetentionPolicyRetain orphans the mysql data PVC so it outlives the Cars instance
+	RetentionPolicyRetain RetentionPolicy = "Retain"
+	// RetentionPolicyDelete deletes the mysql data PVC together with the Cars instance
+	RetentionPolicyDelete RetentionPolicy = "Delete"
+	// RetentionPolicyBackupThenDelete dumps the database to a retained backup PVC before deleting the mysql data PVC
+	RetentionPolicyBackupThenDelete RetentionPolicy = "BackupThenDelete"
+)
+
+// CarsStorageSpec defines how the mysql data is handled
+type CarsStorageSpec struct {
+	// RetentionPolicy is applied to the mysql data PVC when the Cars instance is deleted. Defaults to Retain.
+	// +kubebuilder:default=Retain
+	// +optional
+	RetentionPolicy RetentionPolicy `json:"retentionPolicy,omitempty"`
+}
+
+// CarsPhase is a summary of the Cars status conditions
+type CarsPhase string
+
+const (
+	// CarsPhasePending is when the children are rendered but not all of them are ready yet
+	CarsPhasePending CarsPhase = "Pending"
+	// CarsPhaseRunning is when every component is ready
+	CarsPhaseRunning CarsPhase = "Running"
+	// CarsPhaseFailed is when the last reconcile failed
+	CarsPhaseFailed CarsPhase = "Failed"
+	// CarsPhaseTerminating is when the instance is being deleted
+	CarsPhaseTerminating CarsPhase = "Terminating"
+)
+
 // CarsStatus defines the observed state of Cars
 type CarsStatus struct {
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// ObservedGeneration is the generation of the spec the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Phase summarizes the conditions
+	Phase CarsPhase `json:"phase,omitempty"`
+	// URL is the public address of the cars API when an ingress is configured
+	URL string `json:"url,omitempty"`
+	// Replicas is the number of cars pods
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+	// ReadyReplicas is the number of cars pods passing their readiness probe
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+	// MysqlPVC is the name of the PVC holding the mysql data when it is not <name>-mysql-data, as for the mysql-data
+	// PVC adopted from an install of an earlier operator version
+	// +optional
+	MysqlPVC string `json:"mysqlPVC,omitempty"`
+	// Images are the digests the image tags resolved to when ResolveImageDigests is set
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Images []ImageStatus `json:"images,omitempty"`
+	// Steps is the outcome of each step of the last reconcile
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Steps []ReconcileStepStatus `json:"steps,omitempty"`
+}
+
+// ImageStatus is the digest an image tag resolved to
+type ImageStatus struct {
+	// Name of the container
+	Name string `json:"name"`
+	// Image is the reference from the spec
+	Image string `json:"image"`
+	// Digest of the manifest the reference resolved to
+	Digest string `json:"digest"`
+}
+
+// ReconcileStepStatus is the outcome of a single reconcile step
+type ReconcileStepStatus struct {
+	// Name of the reconcile step
+	Name string `json:"name"`
+	// Result is one of Completed, Skipped, Stopped, Failed or Blocked
+	Result string `json:"result"`
+	// Message holds the error of a failed step
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Network",type=string,JSONPath=`.spec.network`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+//+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.url`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Cars is the Schema for the cars API
 type Cars struct {
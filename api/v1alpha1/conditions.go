@@ -9,5 +9,77 @@ const ReconciledReasonComplete = "Complete"
 // ReconciledReasonError is an error
 const ReconciledReasonError = "Error"
 
+// ReconciledReasonWaiting is when a reconcile step cannot complete yet
+const ReconciledReasonWaiting = "Waiting"
+
+// ReconciledReasonInvalidSpec is when the spec failed validation and nothing was rendered
+const ReconciledReasonInvalidSpec = "InvalidSpec"
+
 // ReconcileCompleteMessage is when the reconile is complete
 const ReconcileCompleteMessage = "Reconcile complete"
+
+// ConditionDatabaseReady is true when the mysql deployment has an available replica
+const ConditionDatabaseReady = "DatabaseReady"
+
+// ConditionStorageBound is true when the mysql data PVC is bound
+const ConditionStorageBound = "StorageBound"
+
+// ConditionAppAvailable is true when the cars deployment has all of its replicas available
+const ConditionAppAvailable = "AppAvailable"
+
+// ConditionIngressReady is true when the ingress has been assigned an address. Absent when no domain is set
+const ConditionIngressReady = "IngressReady"
+
+// ConditionCertificateReady is true when the ingress TLS secret has been issued. Absent when no domain is set, or
+// when neither a cluster issuer nor a TLS secret name provides the certificate
+const ConditionCertificateReady = "CertificateReady"
+
+// ConditionDisruptionAllowed is false when a PodDisruptionBudget currently blocks evictions of the pods it guards.
+// Absent when no PodDisruptionBudget is rendered
+const ConditionDisruptionAllowed = "DisruptionAllowed"
+
+// ConditionFieldsOwned is false when applying a child conflicted with another field manager during the last reconcile
+const ConditionFieldsOwned = "FieldsOwned"
+
+// ConditionPatchesApplied is false when a patch of spec.patches failed to apply during the last reconcile.
+// Absent when no patches are set
+const ConditionPatchesApplied = "PatchesApplied"
+
+// ReasonPatched is when every patch applied to the children it targets
+const ReasonPatched = "Patched"
+
+// ReasonPatchFailed is when a patch could not be applied, the child it targets is not written
+const ReasonPatchFailed = "PatchFailed"
+
+// ReasonApplied is when every child was applied without conflicts
+const ReasonApplied = "Applied"
+
+// ReasonConflict is when another field manager had set fields the operator renders
+const ReasonConflict = "Conflict"
+
+// ReasonAvailable is when a deployment has available replicas
+const ReasonAvailable = "Available"
+
+// ReasonUnavailable is when a deployment is missing available replicas
+const ReasonUnavailable = "Unavailable"
+
+// ReasonNotFound is when the observed object does not exist yet
+const ReasonNotFound = "NotFound"
+
+// ReasonAddressAssigned is when the ingress has a load balancer address
+const ReasonAddressAssigned = "AddressAssigned"
+
+// ReasonAddressPending is when the ingress is waiting for a load balancer address
+const ReasonAddressPending = "AddressPending"
+
+// ReasonCertificateIssued is when the TLS secret holds a certificate
+const ReasonCertificateIssued = "Issued"
+
+// ReasonCertificatePending is when the TLS secret has not been issued yet
+const ReasonCertificatePending = "Pending"
+
+// ReasonEvictionAllowed is when every PodDisruptionBudget allows evicting a pod
+const ReasonEvictionAllowed = "EvictionAllowed"
+
+// ReasonEvictionBlocked is when a PodDisruptionBudget allows no disruptions, so evictions of its pods are refused
+const ReasonEvictionBlocked = "EvictionBlocked"
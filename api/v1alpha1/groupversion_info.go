@@ -36,4 +36,14 @@ var (
 
 	// CarsLabel is the label applied to all created cars resources
 	CarsLabel = "cars.bsvblockchain.com/part-of"
+
+	// NetworkLabel is applied to all created cars resources of an instance serving a network
+	NetworkLabel = "cars.bsvblockchain.com/network"
+
+	// ConfigHashAnnotation is set on the cars pod template to the hash of the rendered configuration, so a
+	// configuration change rolls the pods
+	ConfigHashAnnotation = "cars.bsvblockchain.com/config-hash"
+
+	// CarsFinalizer is the finalizer used to apply the storage retention policy before a cars resource is removed
+	CarsFinalizer = "cars.bsvblockchain.com/finalizer"
 )
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+	"github.com/bitcoin-sv/cars-operator/internal/controller"
+)
+
+// render prints the children a Cars resource is rendered into as a YAML stream, without needing a cluster
+func render(args []string, out io.Writer) error {
+	var file string
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	flags.StringVar(&file, "f", "-", "The Cars YAML file to render, - reads it from stdin.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	cars := infrav1alpha1.Cars{}
+	if _, _, err := serializer.NewCodecFactory(scheme).UniversalDeserializer().Decode(data, nil, &cars); err != nil {
+		return fmt.Errorf("unable to decode Cars resource from %s: %w", file, err)
+	}
+	if cars.Namespace == "" {
+		cars.Namespace = "default"
+	}
+
+	if err := controller.ValidateSpec(&cars); err != nil {
+		return fmt.Errorf("invalid Cars resource: %w", err)
+	}
+
+	for _, obj := range controller.Render(&cars) {
+		gvk, err := apiutil.GVKForObject(obj, scheme)
+		if err != nil {
+			return err
+		}
+		if err := controller.ApplyPatches(obj, gvk.Kind, cars.Spec.Patches); err != nil {
+			return fmt.Errorf("unable to patch %s %s: %w", gvk.Kind, obj.GetName(), err)
+		}
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+		manifest, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(out, "---\n%s", manifest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
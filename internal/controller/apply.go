@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// apply renders a child object with mutate and server-side applies it as FieldManager, so the operator only owns the
+// fields it renders and leaves fields set by other controllers alone. A conflict with another field manager is
+// recorded on the scope before the operator takes the conflicting fields back
+func (r *CarsReconciler) apply(scope *reconcileScope, obj client.Object, mutate controllerutil.MutateFn) (controllerutil.OperationResult, error) {
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	existing, ok := obj.DeepCopyObject().(client.Object)
+	if !ok {
+		return controllerutil.OperationResultNone, fmt.Errorf("%s is not a client object", gvk.Kind)
+	}
+	err = r.Get(scope.Context, client.ObjectKeyFromObject(obj), existing)
+	if client.IgnoreNotFound(err) != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	found := err == nil
+
+	if err := mutate(); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	// A child failing to patch is still rendered, so it is not pruned
+	scope.addRendered(gvk.Kind, obj.GetName())
+	if err := ApplyPatches(obj, gvk.Kind, scope.Cars.Spec.Patches); err != nil {
+		scope.addPatchError(fmt.Sprintf("%s %s: %s", gvk.Kind, obj.GetName(), err))
+		return controllerutil.OperationResultNone, err
+	}
+	// Apply requests must carry the type information
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+
+	err = r.Patch(scope.Context, obj, client.Apply, client.FieldOwner(FieldManager))
+	if k8serrors.IsConflict(err) {
+		scope.addConflict(fmt.Sprintf("%s %s: %s", gvk.Kind, obj.GetName(), err))
+		r.Recorder.Eventf(scope.Cars, corev1.EventTypeWarning, EventReasonFieldConflict, "Took over conflicting fields of %s %s: %s", gvk.Kind, obj.GetName(), err)
+		err = r.Patch(scope.Context, obj, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership)
+	}
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	switch {
+	case !found:
+		return controllerutil.OperationResultCreated, nil
+	case existing.GetResourceVersion() != obj.GetResourceVersion():
+		return controllerutil.OperationResultUpdated, nil
+	default:
+		return controllerutil.OperationResultNone, nil
+	}
+}
+
+// setFieldOwnershipCondition reports the server-side apply conflicts of the last reconcile
+func setFieldOwnershipCondition(scope *reconcileScope) {
+	conflicts := scope.Conflicts()
+	if len(conflicts) == 0 {
+		setComponentCondition(scope.Cars, infrav1alpha1.ConditionFieldsOwned, true, infrav1alpha1.ReasonApplied,
+			fmt.Sprintf("All rendered fields are owned by %s", FieldManager))
+		return
+	}
+	setComponentCondition(scope.Cars, infrav1alpha1.ConditionFieldsOwned, false, infrav1alpha1.ReasonConflict,
+		strings.Join(conflicts, "; "))
+}
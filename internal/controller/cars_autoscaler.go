@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"encoding/json"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+	"github.com/bitcoin-sv/cars-operator/internal/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// ReconcileAutoscaler is the cars HorizontalPodAutoscaler reconciler
+func (r *CarsReconciler) ReconcileAutoscaler(scope *reconcileScope) (utils.StepResult, error) {
+	cars := scope.Cars
+	// Skip if autoscaling isn't enabled, an autoscaler rendered before is pruned
+	if cars.Spec.Autoscaling == nil {
+		return utils.StepSkipped, nil
+	}
+	hpa := renderCarsAutoscaler(cars)
+	op, err := r.apply(scope, hpa, func() error {
+		return controllerutil.SetControllerReference(cars, hpa, r.Scheme)
+	})
+	r.recordResult(cars, "HorizontalPodAutoscaler", hpa.Name, op, err)
+	if err != nil {
+		return utils.StepFailed, err
+	}
+	return utils.StepCompleted, nil
+}
+
+// autoscaledReplicas returns the replicas to apply to the autoscaled cars deployment. Not applying a field the
+// operator is the only manager of resets it to its default of 1, so the live replicas are applied until the
+// HorizontalPodAutoscaler has scaled the deployment and taken the field over
+func (r *CarsReconciler) autoscaledReplicas(scope *reconcileScope, name string) (*int32, error) {
+	dep := appsv1.Deployment{}
+	found, err := r.getChild(scope, name, &dep)
+	if err != nil || !found || dep.Spec.Replicas == nil {
+		return nil, err
+	}
+	for _, entry := range dep.ManagedFields {
+		if entry.Manager == FieldManager || entry.FieldsV1 == nil {
+			continue
+		}
+		fields := map[string]map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			return nil, err
+		}
+		if _, ok := fields["f:spec"]["f:replicas"]; ok {
+			return nil, nil
+		}
+	}
+	return ptr.To(*dep.Spec.Replicas), nil
+}
+
+// renderCarsAutoscaler renders the cars HorizontalPodAutoscaler, leaving the owner reference to the reconciler
+func renderCarsAutoscaler(cars *infrav1alpha1.Cars) *autoscalingv2.HorizontalPodAutoscaler {
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      carsName(cars),
+			Namespace: cars.Namespace,
+			Labels:    getAppLabels(cars),
+		},
+		Spec: *defaultCarsAutoscalerSpec(cars),
+	}
+}
+
+func defaultCarsAutoscalerSpec(cars *infrav1alpha1.Cars) *autoscalingv2.HorizontalPodAutoscalerSpec {
+	autoscaling := cars.Spec.Autoscaling
+	minReplicas := int32(1)
+	if autoscaling.MinReplicas != nil {
+		minReplicas = *autoscaling.MinReplicas
+	}
+	metrics := []autoscalingv2.MetricSpec{}
+	if autoscaling.TargetCPUUtilizationPercentage != nil {
+		metrics = append(metrics, utilizationMetric(corev1.ResourceCPU, *autoscaling.TargetCPUUtilizationPercentage))
+	}
+	if autoscaling.TargetMemoryUtilizationPercentage != nil {
+		metrics = append(metrics, utilizationMetric(corev1.ResourceMemory, *autoscaling.TargetMemoryUtilizationPercentage))
+	}
+	if len(metrics) == 0 {
+		metrics = append(metrics, utilizationMetric(corev1.ResourceCPU, DefaultTargetCPUUtilization))
+	}
+	return &autoscalingv2.HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Name:       carsName(cars),
+		},
+		MinReplicas: ptr.To(minReplicas),
+		MaxReplicas: autoscaling.MaxReplicas,
+		Metrics:     metrics,
+	}
+}
+
+func utilizationMetric(name corev1.ResourceName, utilization int32) autoscalingv2.MetricSpec {
+	return autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name: name,
+			Target: autoscalingv2.MetricTarget{
+				Type:               autoscalingv2.UtilizationMetricType,
+				AverageUtilization: ptr.To(utilization),
+			},
+		},
+	}
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+	"github.com/bitcoin-sv/cars-operator/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/yaml"
+)
+
+// ReconcileConfig is the cars configuration ConfigMap reconciler
+func (r *CarsReconciler) ReconcileConfig(scope *reconcileScope) (utils.StepResult, error) {
+	cars := scope.Cars
+	configMap := renderCarsConfigMap(cars)
+	op, err := r.apply(scope, configMap, func() error {
+		return controllerutil.SetControllerReference(cars, configMap, r.Scheme)
+	})
+	r.recordResult(cars, "ConfigMap", configMap.Name, op, err)
+	if err != nil {
+		return utils.StepFailed, err
+	}
+	return utils.StepCompleted, nil
+}
+
+// renderCarsConfigMap renders the cars configuration ConfigMap, leaving the owner reference to the reconciler
+func renderCarsConfigMap(cars *infrav1alpha1.Cars) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      carsConfigName(cars),
+			Namespace: cars.Namespace,
+			Labels:    getAppLabels(cars),
+		},
+		Data: map[string]string{
+			CarsConfigFile: renderCarsConfig(cars),
+		},
+	}
+}
+
+// renderCarsConfig renders the configuration file of the cars app. Keys are sorted, so the same spec always renders
+// the same file and hash
+func renderCarsConfig(cars *infrav1alpha1.Cars) string {
+	spec := cars.Spec.Config
+	config := map[string]interface{}{}
+	for key, value := range spec.Extra {
+		config[key] = value
+	}
+	config["listenPort"] = carsListenPort(cars)
+	if cars.Spec.Network != "" {
+		config["network"] = cars.Spec.Network
+	}
+	if spec.LogLevel != "" {
+		config["logLevel"] = spec.LogLevel
+	}
+	if len(spec.Broadcasters) > 0 {
+		config["broadcasters"] = spec.Broadcasters
+	}
+	// Marshalling a map of plain values cannot fail
+	data, _ := yaml.Marshal(config)
+	return string(data)
+}
+
+// carsConfigHash hashes the cars ConfigMap as it is applied, patches included, so patching the config rolls the pods.
+// A patch failing to apply fails the ConfigMap step, which then hashes the rendered config
+func carsConfigHash(cars *infrav1alpha1.Cars) string {
+	configMap := renderCarsConfigMap(cars)
+	_ = ApplyPatches(configMap, "ConfigMap", cars.Spec.Patches)
+	return configHash(configMap)
+}
+
+// configHash hashes the data of a ConfigMap for the pod template annotation
+func configHash(configMap *corev1.ConfigMap) string {
+	keys := make([]string, 0, len(configMap.Data))
+	for key := range configMap.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	hash := sha256.New()
+	for _, key := range keys {
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write([]byte(configMap.Data[key]))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// carsListenPort is the port the cars container listens on
+func carsListenPort(cars *infrav1alpha1.Cars) int32 {
+	if cars.Spec.Config.ListenPort != nil {
+		return *cars.Spec.Config.ListenPort
+	}
+	return CarsPort
+}
@@ -18,39 +18,60 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"github.com/bitcoin-sv/cars-operator/internal/registry"
 	"github.com/bitcoin-sv/cars-operator/internal/utils"
-	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
 )
 
-// CarsReconciler reconciles a Cars object
+// CarsReconciler reconciles a Cars object. Per-request state lives in a reconcileScope so reconciles can run concurrently
 type CarsReconciler struct {
 	client.Client
-	Scheme         *runtime.Scheme
-	Log            logr.Logger
-	NamespacedName types.NamespacedName
-	Context        context.Context
+	Scheme                  *runtime.Scheme
+	Recorder                record.EventRecorder
+	MaxConcurrentReconciles int
+	// PruneDryRun logs children that are no longer desired instead of deleting them
+	PruneDryRun bool
+	// Resolver resolves image tags to digests, talking to the registries over HTTPS when nil
+	Resolver registry.Resolver
 }
 
 //+kubebuilder:rbac:groups=infra.bsvblockchain.com,resources=cars,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=infra.bsvblockchain.com,resources=cars/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infra.bsvblockchain.com,resources=cars/finalizers,verbs=update
-//+kubebuilder:rbac:groups="",resources=endpoints;configmaps;services;secrets;persistentvolumeclaims,verbs=get;create;update;list;watch
-//+kubebuilder:rbac:groups="apps",resources=deployments,verbs=get;update;create;list;watch
-//+kubebuilder:rbac:groups="networking.k8s.io",resources=ingresses,verbs=get;update;create;list;watch
+//+kubebuilder:rbac:groups="",resources=endpoints;configmaps;services;secrets;persistentvolumeclaims,verbs=get;create;update;patch;list;watch
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims;services;configmaps,verbs=delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="batch",resources=jobs,verbs=get;create;list;watch
+//+kubebuilder:rbac:groups="apps",resources=deployments,verbs=get;update;patch;create;list;watch;delete
+//+kubebuilder:rbac:groups="networking.k8s.io",resources=ingresses,verbs=get;update;patch;create;list;watch;delete
+//+kubebuilder:rbac:groups="autoscaling",resources=horizontalpodautoscalers,verbs=get;update;patch;create;list;watch;delete
+//+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;update;patch;create;list;watch;delete
+//+kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=roles;rolebindings,verbs=get;update;patch;create;list;watch;delete
+//+kubebuilder:rbac:groups="policy",resources=poddisruptionbudgets,verbs=get;update;patch;create;list;watch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -59,67 +80,225 @@ type CarsReconciler struct {
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.17.3/pkg/reconcile
 func (r *CarsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	result := ctrl.Result{}
-	r.Log = log.FromContext(ctx).WithValues("cars", req.NamespacedName)
-	r.Context = ctx
-	r.NamespacedName = req.NamespacedName
+	logger := log.FromContext(ctx).WithValues("cars", req.NamespacedName)
 
 	cars := infrav1alpha1.Cars{}
 	if err := r.Get(ctx, req.NamespacedName, &cars); err != nil {
-		r.Log.Error(err, "unable to fetch CARS CR")
+		logger.Error(err, "unable to fetch CARS CR")
 		return result, nil
 	}
+	scope := &reconcileScope{
+		Context: ctx,
+		Log:     logger,
+		Cars:    &cars,
+	}
 
-	_, err := utils.ReconcileBatch(r.Log,
-		r.ReconcileDeployment,
-		r.ReconcileService,
-		r.ReconcileIngress,
-		r.ReconcileMysqlDeployment,
-		r.ReconcileMysqlService,
-		r.ReconcileMysqlPVC,
-	)
+	if !cars.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(scope)
+	}
+	if controllerutil.AddFinalizer(&cars, infrav1alpha1.CarsFinalizer) {
+		if err := r.Update(ctx, &cars); err != nil {
+			return result, err
+		}
+	}
+
+	if err := ValidateSpec(&cars); err != nil {
+		return r.rejectSpec(scope, err)
+	}
+
+	outcomes, err := utils.ReconcileSteps(scope, r.reconcileSteps()...)
+	if err == nil && len(stoppedSteps(outcomes)) == 0 {
+		// Only prune once every step rendered its children, so the desired set is complete
+		err = r.pruneChildren(scope)
+	}
+	cars.Status.Steps = stepStatuses(outcomes)
+	if images, ok := scope.Images(); ok {
+		cars.Status.Images = images
+	}
+	setFieldOwnershipCondition(scope)
+	setPatchesCondition(scope)
 
 	if err != nil {
 		apimeta.SetStatusCondition(&cars.Status.Conditions,
 			metav1.Condition{
-				Type:    infrav1alpha1.ConditionReconciled,
-				Status:  metav1.ConditionFalse,
-				Reason:  infrav1alpha1.ReconciledReasonError,
-				Message: err.Error(),
+				Type:               infrav1alpha1.ConditionReconciled,
+				Status:             metav1.ConditionFalse,
+				Reason:             infrav1alpha1.ReconciledReasonError,
+				Message:            err.Error(),
+				ObservedGeneration: cars.Generation,
 			},
 		)
+		_ = r.observeStatus(scope)
 		_ = r.Client.Status().Update(ctx, &cars)
 		// Since error is written on the status, let's log it and requeue
 		// Returning error here is redundant
 		return ctrl.Result{Requeue: true, RequeueAfter: time.Second}, err
+	} else if stopped := stoppedSteps(outcomes); len(stopped) > 0 {
+		apimeta.SetStatusCondition(&cars.Status.Conditions,
+			metav1.Condition{
+				Type:               infrav1alpha1.ConditionReconciled,
+				Status:             metav1.ConditionFalse,
+				Reason:             infrav1alpha1.ReconciledReasonWaiting,
+				Message:            fmt.Sprintf("Waiting on %s", strings.Join(stopped, ", ")),
+				ObservedGeneration: cars.Generation,
+			},
+		)
 	} else {
 		apimeta.SetStatusCondition(&cars.Status.Conditions,
 			metav1.Condition{
-				Type:    infrav1alpha1.ConditionReconciled,
-				Status:  metav1.ConditionTrue,
-				Reason:  infrav1alpha1.ReconciledReasonComplete,
-				Message: infrav1alpha1.ReconcileCompleteMessage,
+				Type:               infrav1alpha1.ConditionReconciled,
+				Status:             metav1.ConditionTrue,
+				Reason:             infrav1alpha1.ReconciledReasonComplete,
+				Message:            infrav1alpha1.ReconcileCompleteMessage,
+				ObservedGeneration: cars.Generation,
 			},
 		)
 	}
-	err = r.Client.Status().Update(ctx, &cars)
+	if err = r.observeStatus(scope); err != nil {
+		return result, err
+	}
+	if err = r.Client.Status().Update(ctx, &cars); err != nil {
+		return result, err
+	}
+
+	// Status changes of the children are watched, only steps waiting on something else need polling
+	if len(stoppedSteps(outcomes)) > 0 {
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	}
+	return certificateRequeue(&cars), nil
+}
 
-	return ctrl.Result{Requeue: false, RequeueAfter: 0}, err
+// rejectSpec reports an invalid spec without rendering anything. The next spec change triggers a new reconcile
+func (r *CarsReconciler) rejectSpec(scope *reconcileScope, err error) (ctrl.Result, error) {
+	cars := scope.Cars
+	scope.Log.Info("rejecting invalid spec", "reason", err.Error())
+	r.Recorder.Eventf(cars, corev1.EventTypeWarning, EventReasonInvalidSpec, "Invalid spec: %s", err)
+	apimeta.SetStatusCondition(&cars.Status.Conditions,
+		metav1.Condition{
+			Type:               infrav1alpha1.ConditionReconciled,
+			Status:             metav1.ConditionFalse,
+			Reason:             infrav1alpha1.ReconciledReasonInvalidSpec,
+			Message:            err.Error(),
+			ObservedGeneration: cars.Generation,
+		},
+	)
+	if err := r.observeStatus(scope); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, r.Client.Status().Update(scope.Context, cars)
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// reconcileSteps lists the reconcile steps and what they depend on. The database is brought up before the cars app,
+// while steps that do not depend on each other run in parallel
+func (r *CarsReconciler) reconcileSteps() []utils.Step[*reconcileScope] {
+	return []utils.Step[*reconcileScope]{
+		{Name: StepMysqlPVC, Run: r.ReconcileMysqlPVC},
+		{Name: StepImages, Run: r.ReconcileImages},
+		{Name: StepMysqlDeployment, DependsOn: []string{StepMysqlPVC, StepImages}, Run: r.ReconcileMysqlDeployment},
+		{Name: StepMysqlService, DependsOn: []string{StepMysqlDeployment}, Run: r.ReconcileMysqlService},
+		{Name: StepCarsConfig, Run: r.ReconcileConfig},
+		{Name: StepNetworkKeys, Run: r.ReconcileNetworkKeys},
+		{Name: StepServiceAccount, Run: r.ReconcileServiceAccount},
+		{Name: StepRole, Run: r.ReconcileRole},
+		{Name: StepRoleBinding, DependsOn: []string{StepServiceAccount, StepRole}, Run: r.ReconcileRoleBinding},
+		{Name: StepCarsDeployment, DependsOn: []string{StepMysqlService, StepCarsConfig, StepNetworkKeys, StepImages, StepRoleBinding}, Run: r.ReconcileDeployment},
+		{Name: StepCarsService, Run: r.ReconcileService},
+		{Name: StepIngress, DependsOn: []string{StepCarsService}, Run: r.ReconcileIngress},
+		{Name: StepCarsAutoscaler, DependsOn: []string{StepCarsDeployment}, Run: r.ReconcileAutoscaler},
+		{Name: StepMysqlPDB, DependsOn: []string{StepMysqlDeployment}, Run: r.ReconcileMysqlPDB},
+		{Name: StepCarsPDB, DependsOn: []string{StepCarsDeployment}, Run: r.ReconcileCarsPDB},
+	}
+}
+
+// stepStatuses converts the step outcomes into their status representation
+func stepStatuses(outcomes []utils.StepOutcome) []infrav1alpha1.ReconcileStepStatus {
+	statuses := make([]infrav1alpha1.ReconcileStepStatus, 0, len(outcomes))
+	for _, outcome := range outcomes {
+		status := infrav1alpha1.ReconcileStepStatus{
+			Name:   outcome.Name,
+			Result: string(outcome.Result),
+		}
+		if outcome.Err != nil {
+			status.Message = outcome.Err.Error()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// stoppedSteps returns the names of the steps that could not complete yet
+func stoppedSteps(outcomes []utils.StepOutcome) []string {
+	stopped := []string{}
+	for _, outcome := range outcomes {
+		if outcome.Result == utils.StepStopped {
+			stopped = append(stopped, outcome.Name)
+		}
+	}
+	return stopped
+}
+
+// SetupWithManager sets up the controller with the Manager. Spec changes of a Cars instance and deletions or drift
+// of its children re-render the children, while status changes of the children only refresh the Cars status
 func (r *CarsReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&infrav1alpha1.Cars{}).
-		Owns(&appsv1.Deployment{}).
-		Owns(&corev1.Service{}).
-		Owns(&corev1.PersistentVolumeClaim{}).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
-		Complete(r)
+	options := controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}
+
+	carsController := ctrl.NewControllerManagedBy(mgr).
+		WithOptions(options).
+		For(&infrav1alpha1.Cars{}, builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	for _, child := range ownedTypes() {
+		carsController = carsController.Owns(child, builder.WithPredicates(childSpecChanged()))
+	}
+	if err := carsController.Complete(r); err != nil {
+		return err
+	}
+
+	statusController := ctrl.NewControllerManagedBy(mgr).
+		Named("cars-status").
+		WithOptions(options)
+	for _, child := range ownedTypes() {
+		statusController = statusController.Watches(child,
+			handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(), &infrav1alpha1.Cars{}, handler.OnlyControllerOwner()),
+			builder.WithPredicates(childStatusChanged()))
+	}
+	return statusController.Complete(reconcile.Func(r.ReconcileStatus))
+}
+
+// ownedTypes lists the kinds of children a Cars instance controls
+func ownedTypes() []client.Object {
+	return []client.Object{
+		&appsv1.Deployment{},
+		&corev1.Service{},
+		&corev1.PersistentVolumeClaim{},
+		&corev1.ConfigMap{},
+		&networkingv1.Ingress{},
+		&autoscalingv2.HorizontalPodAutoscaler{},
+		&policyv1.PodDisruptionBudget{},
+		&corev1.ServiceAccount{},
+		&rbacv1.Role{},
+		&rbacv1.RoleBinding{},
+	}
 }
 
-// getAppLabels defines the label applied to created resources. This label is used by the predicate to determine which resources are ours
-func getAppLabels() map[string]string {
-	return map[string]string{
-		infrav1alpha1.CarsLabel: "true",
+// recordResult emits an event on the Cars resource for the outcome of a create or update of one of its children
+func (r *CarsReconciler) recordResult(cars *infrav1alpha1.Cars, kind string, name string, op controllerutil.OperationResult, err error) {
+	switch {
+	case err != nil:
+		r.Recorder.Eventf(cars, corev1.EventTypeWarning, EventReasonFailed, "Failed to reconcile %s %s: %s", kind, name, err)
+	case op == controllerutil.OperationResultCreated:
+		r.Recorder.Eventf(cars, corev1.EventTypeNormal, EventReasonCreated, "Created %s %s", kind, name)
+	case op == controllerutil.OperationResultUpdated:
+		r.Recorder.Eventf(cars, corev1.EventTypeNormal, EventReasonUpdated, "Updated %s %s", kind, name)
+	}
+}
+
+// getAppLabels defines the labels applied to created resources. They name the Cars instance the resources are part of,
+// which is used to take the inventory of children to prune, and the network it serves
+func getAppLabels(cars *infrav1alpha1.Cars) map[string]string {
+	labels := map[string]string{
+		infrav1alpha1.CarsLabel: cars.Name,
+	}
+	if cars.Spec.Network != "" {
+		labels[infrav1alpha1.NetworkLabel] = string(cars.Spec.Network)
 	}
+	return labels
 }
@@ -18,18 +18,79 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+	"github.com/bitcoin-sv/cars-operator/internal/registry"
+	"github.com/bitcoin-sv/cars-operator/internal/utils"
 )
 
+// drainEvents returns the events recorded so far
+func drainEvents(recorder *record.FakeRecorder) []string {
+	events := []string{}
+	for len(recorder.Events) > 0 {
+		events = append(events, <-recorder.Events)
+	}
+	return events
+}
+
+// carsKey is the key of the cars children of the instance with the given key
+func carsKey(key types.NamespacedName) types.NamespacedName {
+	return types.NamespacedName{Name: key.Name + "-cars", Namespace: key.Namespace}
+}
+
+// createCars creates a Cars instance with the given spec and returns a reconciler recording its events in a fake
+// recorder. The instance is deleted when the spec ends, reconciling it once more to release the finalizer
+func createCars(ctx context.Context, key types.NamespacedName, spec infrav1alpha1.CarsSpec) (*infrav1alpha1.Cars, *CarsReconciler) {
+	instance := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.Name,
+			Namespace: key.Namespace,
+		},
+		Spec: spec,
+	}
+	Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+	controllerReconciler := &CarsReconciler{
+		Client:   k8sClient,
+		Scheme:   k8sClient.Scheme(),
+		Recorder: record.NewFakeRecorder(100),
+	}
+	DeferCleanup(func() {
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, instance))).To(Succeed())
+		_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+	})
+	return instance, controllerReconciler
+}
+
 var _ = Describe("Cars Controller", func() {
 	Context("When reconciling a resource", func() {
 		const resourceName = "test-resource"
@@ -65,12 +126,24 @@ var _ = Describe("Cars Controller", func() {
 
 			By("Cleanup the specific resource instance Cars")
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+
+			By("Releasing the finalizer")
+			controllerReconciler := &CarsReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(100),
+			}
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
 		})
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
 			controllerReconciler := &CarsReconciler{
-				Client: k8sClient,
-				Scheme: k8sClient.Scheme(),
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(100),
 			}
 
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
@@ -80,5 +153,1081 @@ var _ = Describe("Cars Controller", func() {
 			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
+		It("should reconcile the database when no domain is set", func() {
+			controllerReconciler := &CarsReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(100),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			mysql := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-mysql", Namespace: "default"}, mysql)).To(Succeed())
+
+			resource := &infrav1alpha1.Cars{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.Steps).To(ContainElement(infrav1alpha1.ReconcileStepStatus{
+				Name:   StepIngress,
+				Result: string(utils.StepSkipped),
+			}))
+			Expect(resource.Status.Steps).To(ContainElement(infrav1alpha1.ReconcileStepStatus{
+				Name:   StepCarsDeployment,
+				Result: string(utils.StepCompleted),
+			}))
+		})
+		It("should record events for the children it creates", func() {
+			key := types.NamespacedName{Name: "test-events", Namespace: "default"}
+			_, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{})
+			recorder := controllerReconciler.Recorder.(*record.FakeRecorder)
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			// Independent steps run in parallel, so the events come in no particular order
+			Expect(drainEvents(recorder)).To(ContainElements(
+				"Normal Created Created Deployment "+carsKey(key).Name,
+				"Normal Created Created Service "+carsKey(key).Name,
+				"Normal Created Created Deployment "+key.Name+"-mysql",
+				"Normal Created Created Service "+key.Name+"-mysql",
+			))
+		})
+
+		It("should expose the configured environment sources to the containers", func() {
+			key := types.NamespacedName{Name: "test-env", Namespace: "default"}
+			_, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+				EnvFrom: []corev1.EnvFromSource{
+					{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "test-env-keys"}}},
+				},
+				Env: []corev1.EnvVar{
+					{Name: "LOG_LEVEL", Value: "debug"},
+				},
+			})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			container := dep.Spec.Template.Spec.Containers[0]
+			Expect(container.EnvFrom).To(HaveLen(1))
+			Expect(container.EnvFrom[0].SecretRef.Name).To(Equal("test-env-keys"))
+			Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"}))
+
+			mysql := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: key.Name + "-mysql", Namespace: key.Namespace}, mysql)).To(Succeed())
+			Expect(mysql.Spec.Template.Spec.Containers[0].EnvFrom[0].SecretRef.Name).To(Equal(MysqlEnvironmentSecret))
+		})
+
+		It("should render the configuration into a mounted ConfigMap", func() {
+			key := types.NamespacedName{Name: "test-config", Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+				Config: infrav1alpha1.CarsConfigSpec{
+					LogLevel: "info",
+				},
+			})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			configMap := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: key.Name + "-config", Namespace: key.Namespace}, configMap)).To(Succeed())
+			Expect(configMap.Data[CarsConfigFile]).To(ContainSubstring("logLevel: info"))
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Volumes[0].ConfigMap.Name).To(Equal(configMap.Name))
+			hash := dep.Spec.Template.Annotations[infrav1alpha1.ConfigHashAnnotation]
+			Expect(hash).NotTo(BeEmpty())
+
+			By("changing the configuration")
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			instance.Spec.Config.LogLevel = "debug"
+			Expect(k8sClient.Update(ctx, instance)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(configMap), configMap)).To(Succeed())
+			Expect(configMap.Data[CarsConfigFile]).To(ContainSubstring("logLevel: debug"))
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			Expect(dep.Spec.Template.Annotations[infrav1alpha1.ConfigHashAnnotation]).NotTo(Equal(hash))
+		})
+
+		It("should inject the keys of the configured network once they exist", func() {
+			key := types.NamespacedName{Name: "test-network", Namespace: "default"}
+			keys := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name + "-keys",
+					Namespace: key.Namespace,
+				},
+				StringData: map[string]string{
+					"TESTNET_PRIVATE_KEY": "private",
+				},
+			}
+			Expect(k8sClient.Create(ctx, keys)).To(Succeed())
+			_, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+				Network: infrav1alpha1.NetworkTestnet,
+				EnvFrom: []corev1.EnvFromSource{
+					{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: keys.Name}}},
+				},
+			})
+
+			By("reporting the missing testnet API key")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).To(MatchError(ContainSubstring("missing the testnet keys TAAL_API_KEY_TEST")))
+			err = k8sClient.Get(ctx, carsKey(key), &appsv1.Deployment{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			By("adding the key")
+			keys.StringData = map[string]string{"TAAL_API_KEY_TEST": "api"}
+			Expect(k8sClient.Update(ctx, keys)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			Expect(dep.Labels).To(HaveKeyWithValue(infrav1alpha1.NetworkLabel, "testnet"))
+			Expect(dep.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "NETWORK", Value: "testnet"}))
+			Expect(dep.Spec.Template.Spec.Containers[0].EnvFrom).To(BeEmpty())
+			service := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, carsKey(key), service)).To(Succeed())
+			Expect(service.Labels).To(HaveKeyWithValue(infrav1alpha1.NetworkLabel, "testnet"))
+		})
+
+		It("should pin the images to the digests their tags resolve to", func() {
+			digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+			registryServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				switch req.URL.Path {
+				case "/v2/galtbv/cars/manifests/v1", "/v2/mysql/manifests/8.0":
+					w.Header().Set("Docker-Content-Digest", digest)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer registryServer.Close()
+			host := strings.TrimPrefix(registryServer.URL, "https://")
+
+			key := types.NamespacedName{Name: "test-digests", Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+				Image:               host + "/galtbv/cars:v1",
+				ImagePullPolicy:     corev1.PullIfNotPresent,
+				ImagePullSecrets:    []corev1.LocalObjectReference{{Name: "test-digests-pull"}},
+				ResolveImageDigests: true,
+				Database: infrav1alpha1.CarsDatabaseSpec{
+					Image: host + "/mysql:8.0",
+				},
+			})
+			controllerReconciler.Resolver = &registry.HTTPResolver{Client: registryServer.Client()}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			Expect(instance.Status.Images).To(ConsistOf(
+				infrav1alpha1.ImageStatus{Name: "cars", Image: host + "/galtbv/cars:v1", Digest: digest},
+				infrav1alpha1.ImageStatus{Name: "mysql", Image: host + "/mysql:8.0", Digest: digest},
+			))
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal(host + "/galtbv/cars:v1@" + digest))
+			Expect(dep.Spec.Template.Spec.Containers[0].ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+			Expect(dep.Spec.Template.Spec.ImagePullSecrets).To(ConsistOf(corev1.LocalObjectReference{Name: "test-digests-pull"}))
+			mysql := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: key.Name + "-mysql", Namespace: key.Namespace}, mysql)).To(Succeed())
+			Expect(mysql.Spec.Template.Spec.Containers[0].Image).To(Equal(host + "/mysql:8.0@" + digest))
+
+			By("keeping the pinned digest when the tag cannot be resolved")
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			instance.Spec.Image = host + "/galtbv/cars:missing"
+			Expect(k8sClient.Update(ctx, instance)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).To(HaveOccurred())
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal(host + "/galtbv/cars:v1@" + digest))
+		})
+
+		It("should schedule the database on the configured nodes", func() {
+			key := types.NamespacedName{Name: "test-scheduling", Namespace: "default"}
+			_, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+				Database: infrav1alpha1.CarsDatabaseSpec{
+					Scheduling: infrav1alpha1.CarsSchedulingSpec{
+						NodeSelector: map[string]string{"pool": "storage-optimized"},
+						Tolerations: []corev1.Toleration{
+							{Key: "storage", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+						},
+					},
+				},
+			})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			mysql := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: key.Name + "-mysql", Namespace: key.Namespace}, mysql)).To(Succeed())
+			Expect(mysql.Spec.Template.Spec.NodeSelector).To(HaveKeyWithValue("pool", "storage-optimized"))
+			Expect(mysql.Spec.Template.Spec.Tolerations).To(HaveLen(1))
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.NodeSelector).To(BeEmpty())
+			Expect(dep.Spec.Template.Spec.Affinity.PodAntiAffinity).NotTo(BeNil())
+		})
+
+		It("should name child resources after the Cars instance", func() {
+			// The cars children of test-names-mysql must not take the names of the mysql children of test-names
+			names := []string{"test-names", "test-names-mysql"}
+			for _, name := range names {
+				key := types.NamespacedName{Name: name, Namespace: "default"}
+				_, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+					Domain:        "example.com",
+					ClusterIssuer: "letsencrypt",
+				})
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			By("checking each instance has its own deployments, services, ingress and PVC")
+			for _, name := range names {
+				dep := &appsv1.Deployment{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name + "-cars", Namespace: "default"}, dep)).To(Succeed())
+				Expect(dep.Spec.Selector.MatchLabels).To(Equal(map[string]string{InstanceLabel: name, ComponentLabel: ComponentCars}))
+
+				svc := &corev1.Service{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name + "-cars", Namespace: "default"}, svc)).To(Succeed())
+				Expect(svc.Spec.Selector).To(Equal(dep.Spec.Selector.MatchLabels))
+
+				ingress := &networkingv1.Ingress{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name + "-cars", Namespace: "default"}, ingress)).To(Succeed())
+				Expect(ingress.Spec.TLS[0].SecretName).To(Equal(name + "-tls"))
+				Expect(ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name).To(Equal(name + "-cars"))
+
+				mysql := &appsv1.Deployment{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name + "-mysql", Namespace: "default"}, mysql)).To(Succeed())
+				Expect(mysql.Spec.Selector.MatchLabels).To(Equal(map[string]string{InstanceLabel: name, ComponentLabel: ComponentMysql}))
+				Expect(mysql.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal(name + "-mysql-data"))
+
+				mysqlSvc := &corev1.Service{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name + "-mysql", Namespace: "default"}, mysqlSvc)).To(Succeed())
+				Expect(mysqlSvc.Spec.Selector).To(Equal(mysql.Spec.Selector.MatchLabels))
+
+				pvc := &corev1.PersistentVolumeClaim{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name + "-mysql-data", Namespace: "default"}, pvc)).To(Succeed())
+			}
+		})
+	})
+
+	Context("When reconciling resources concurrently", func() {
+		ctx := context.Background()
+
+		It("should keep the children of each instance separate", func() {
+			instances := []*infrav1alpha1.Cars{}
+			for i := 0; i < 5; i++ {
+				key := types.NamespacedName{Name: fmt.Sprintf("test-parallel-%d", i), Namespace: "default"}
+				instance, _ := createCars(ctx, key, infrav1alpha1.CarsSpec{
+					Domain: "example.com",
+					Image:  fmt.Sprintf("docker.io/galtbv/cars:v%d", i),
+				})
+				instances = append(instances, instance)
+			}
+			// A single reconciler serves every instance, like the manager runs it
+			controllerReconciler := &CarsReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(100),
+			}
+
+			By("reconciling every instance at the same time")
+			var wg sync.WaitGroup
+			for _, instance := range instances {
+				wg.Add(1)
+				go func(key types.NamespacedName) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+					Expect(err).NotTo(HaveOccurred())
+				}(client.ObjectKeyFromObject(instance))
+			}
+			wg.Wait()
+
+			By("checking every child is owned by and rendered for its own instance")
+			for _, instance := range instances {
+				dep := &appsv1.Deployment{}
+				Expect(k8sClient.Get(ctx, carsKey(client.ObjectKeyFromObject(instance)), dep)).To(Succeed())
+				Expect(metav1.GetControllerOf(dep).UID).To(Equal(instance.UID))
+				Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal(instance.Spec.Image))
+
+				mysql := &appsv1.Deployment{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: instance.Name + "-mysql", Namespace: "default"}, mysql)).To(Succeed())
+				Expect(metav1.GetControllerOf(mysql).UID).To(Equal(instance.UID))
+
+				ingress := &networkingv1.Ingress{}
+				Expect(k8sClient.Get(ctx, carsKey(client.ObjectKeyFromObject(instance)), ingress)).To(Succeed())
+				Expect(metav1.GetControllerOf(ingress).UID).To(Equal(instance.UID))
+				Expect(ingress.Spec.Rules[0].Host).To(Equal(instance.Name + ".example.com"))
+			}
+		})
+	})
+
+	Context("When the spec is invalid", func() {
+		ctx := context.Background()
+
+		It("should report resource requests above their limits without rendering", func() {
+			key := types.NamespacedName{Name: "test-invalid-resources", Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+				Database: infrav1alpha1.CarsDatabaseSpec{
+					Resources: &corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+						Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+					},
+				},
+			})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			condition := apimeta.FindStatusCondition(instance.Status.Conditions, infrav1alpha1.ConditionReconciled)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(infrav1alpha1.ReconciledReasonInvalidSpec))
+			Expect(condition.Message).To(ContainSubstring("spec.database.resources.requests[memory]"))
+			Expect(instance.Status.Phase).To(Equal(infrav1alpha1.CarsPhaseFailed))
+
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: key.Name + "-mysql", Namespace: key.Namespace}, &appsv1.Deployment{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Context("When scaling a resource", func() {
+		ctx := context.Background()
+
+		It("should leave the replicas to the autoscaler when autoscaling is enabled", func() {
+			key := types.NamespacedName{Name: "test-scaling", Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+				Replicas: ptr.To(int32(2)),
+			})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			Expect(*dep.Spec.Replicas).To(Equal(int32(2)))
+
+			By("enabling autoscaling")
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			instance.Spec.Autoscaling = &infrav1alpha1.CarsAutoscalingSpec{
+				MinReplicas:                    ptr.To(int32(2)),
+				MaxReplicas:                    6,
+				TargetCPUUtilizationPercentage: ptr.To(int32(70)),
+			}
+			Expect(k8sClient.Update(ctx, instance)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, carsKey(key), hpa)).To(Succeed())
+			Expect(*hpa.Spec.MinReplicas).To(Equal(int32(2)))
+			Expect(hpa.Spec.MaxReplicas).To(Equal(int32(6)))
+			Expect(hpa.Spec.ScaleTargetRef.Name).To(Equal(carsKey(key).Name))
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			Expect(*dep.Spec.Replicas).To(Equal(int32(2)))
+
+			By("scaling the deployment like the autoscaler would")
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			dep.Spec.Replicas = ptr.To(int32(5))
+			Expect(k8sClient.Update(ctx, dep, client.FieldOwner("horizontal-pod-autoscaler"))).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			Expect(*dep.Spec.Replicas).To(Equal(int32(5)))
+
+			By("rejecting a minimum above the maximum")
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			instance.Spec.Autoscaling.MinReplicas = ptr.To(int32(7))
+			Expect(k8sClient.Update(ctx, instance)).NotTo(Succeed())
+		})
+	})
+
+	Context("When exposing the cars API", func() {
+		ctx := context.Background()
+
+		It("should recreate the headless service as a load balancer", func() {
+			key := types.NamespacedName{Name: "test-service", Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			svc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, carsKey(key), svc)).To(Succeed())
+			Expect(svc.Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+
+			By("rejecting source ranges without a load balancer")
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			instance.Spec.Service.LoadBalancerSourceRanges = []string{"10.0.0.0/8"}
+			Expect(k8sClient.Update(ctx, instance)).NotTo(Succeed())
+
+			By("switching to a load balancer")
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			instance.Spec.Service = infrav1alpha1.CarsServiceSpec{
+				Type:                     corev1.ServiceTypeLoadBalancer,
+				Annotations:              map[string]string{"example.com/lb": "internal"},
+				LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+				ExternalTrafficPolicy:    corev1.ServiceExternalTrafficPolicyLocal,
+			}
+			Expect(k8sClient.Update(ctx, instance)).To(Succeed())
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).NotTo(BeZero())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, carsKey(key), svc)).To(Succeed())
+			Expect(svc.Spec.Type).To(Equal(corev1.ServiceTypeLoadBalancer))
+			Expect(svc.Spec.ClusterIP).NotTo(Equal(corev1.ClusterIPNone))
+			Expect(svc.Annotations).To(HaveKeyWithValue("example.com/lb", "internal"))
+			Expect(svc.Spec.LoadBalancerSourceRanges).To(Equal([]string{"10.0.0.0/8"}))
+			Expect(svc.Spec.ExternalTrafficPolicy).To(Equal(corev1.ServiceExternalTrafficPolicyLocal))
+		})
+	})
+
+	Context("When patching the children", func() {
+		ctx := context.Background()
+
+		It("should apply the patches and report the ones failing", func() {
+			key := types.NamespacedName{Name: "test-patches", Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+				Patches: []infrav1alpha1.CarsPatch{
+					{
+						Target: infrav1alpha1.PatchTarget{Kind: "Service", Name: carsKey(key).Name},
+						Patch:  `{"metadata": {"annotations": {"example.com/internal": "true"}}}`,
+					},
+				},
+			})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			svc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, carsKey(key), svc)).To(Succeed())
+			Expect(svc.Annotations).To(HaveKeyWithValue("example.com/internal", "true"))
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			Expect(apimeta.IsStatusConditionTrue(instance.Status.Conditions, infrav1alpha1.ConditionPatchesApplied)).To(BeTrue())
+			Expect(instance.Spec.Patches[0].Type).To(Equal(infrav1alpha1.PatchTypeStrategic))
+
+			By("adding a patch that cannot apply")
+			instance.Spec.Patches = append(instance.Spec.Patches, infrav1alpha1.CarsPatch{
+				Target: infrav1alpha1.PatchTarget{Kind: "Service", Name: carsKey(key).Name},
+				Type:   infrav1alpha1.PatchTypeJSON6902,
+				Patch:  `[{"op": "replace", "path": "/spec/missing/field", "value": 1}]`,
+			})
+			Expect(k8sClient.Update(ctx, instance)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).To(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			condition := apimeta.FindStatusCondition(instance.Status.Conditions, infrav1alpha1.ConditionPatchesApplied)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(infrav1alpha1.ReasonPatchFailed))
+			Expect(condition.Message).To(ContainSubstring("spec.patches[1]"))
+			Expect(k8sClient.Get(ctx, carsKey(key), svc)).To(Succeed())
+		})
+	})
+
+	Context("When overlaying the pod templates", func() {
+		ctx := context.Background()
+
+		It("should add a sidecar and reject overlays removing the cars container", func() {
+			key := types.NamespacedName{Name: "test-pod-template", Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+				PodTemplate: &runtime.RawExtension{
+					Raw: []byte(`{"spec":{"containers":[{"name":"log-forwarder","image":"fluent-bit"}]}}`),
+				},
+			})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			names := []string{}
+			for _, container := range dep.Spec.Template.Spec.Containers {
+				names = append(names, container.Name)
+			}
+			Expect(names).To(Equal([]string{"cars", "log-forwarder"}))
+
+			By("removing the cars container")
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			instance.Spec.PodTemplate = &runtime.RawExtension{
+				Raw: []byte(`{"spec":{"containers":[{"name":"cars","$patch":"delete"}]}}`),
+			}
+			Expect(k8sClient.Update(ctx, instance)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			condition := apimeta.FindStatusCondition(instance.Status.Conditions, infrav1alpha1.ConditionReconciled)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Reason).To(Equal(infrav1alpha1.ReconciledReasonInvalidSpec))
+			Expect(condition.Message).To(ContainSubstring("spec.podTemplate.spec.containers"))
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers).To(HaveLen(2))
+		})
+	})
+
+	Context("When granting the cars pods access", func() {
+		ctx := context.Background()
+
+		It("should render a namespaced identity unless an existing account is referenced", func() {
+			key := types.NamespacedName{Name: "test-identity", Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, carsKey(key), &corev1.ServiceAccount{})).To(Succeed())
+			role := &rbacv1.Role{}
+			Expect(k8sClient.Get(ctx, carsKey(key), role)).To(Succeed())
+			Expect(role.Rules).NotTo(BeEmpty())
+			binding := &rbacv1.RoleBinding{}
+			Expect(k8sClient.Get(ctx, carsKey(key), binding)).To(Succeed())
+			Expect(binding.RoleRef.Name).To(Equal(carsKey(key).Name))
+			Expect(binding.Subjects).To(ConsistOf(rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: carsKey(key).Name, Namespace: key.Namespace}))
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.ServiceAccountName).To(Equal(carsKey(key).Name))
+
+			By("referencing an existing account")
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			instance.Spec.ServiceAccountName = "existing"
+			Expect(k8sClient.Update(ctx, instance)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.ServiceAccountName).To(Equal("existing"))
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, carsKey(key), &corev1.ServiceAccount{}))).To(BeTrue())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, carsKey(key), &rbacv1.Role{}))).To(BeTrue())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, carsKey(key), &rbacv1.RoleBinding{}))).To(BeTrue())
+		})
+	})
+
+	Context("When guarding against disruptions", func() {
+		ctx := context.Background()
+
+		It("should render disruption budgets and report blocked evictions", func() {
+			key := types.NamespacedName{Name: "test-disruption", Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+				Replicas: ptr.To(int32(2)),
+			})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			mysqlKey := types.NamespacedName{Name: key.Name + "-mysql", Namespace: key.Namespace}
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, mysqlKey, &policyv1.PodDisruptionBudget{}))).To(BeTrue())
+
+			By("opting in to the strict database budget")
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			instance.Spec.Disruption.Database.MaxUnavailable = ptr.To(intstr.FromInt(0))
+			Expect(k8sClient.Update(ctx, instance)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			carsPDB := &policyv1.PodDisruptionBudget{}
+			Expect(k8sClient.Get(ctx, carsKey(key), carsPDB)).To(Succeed())
+			Expect(carsPDB.Spec.MaxUnavailable.IntValue()).To(Equal(1))
+			mysqlPDB := &policyv1.PodDisruptionBudget{}
+			Expect(k8sClient.Get(ctx, mysqlKey, mysqlPDB)).To(Succeed())
+			Expect(mysqlPDB.Spec.MaxUnavailable.IntValue()).To(Equal(0))
+
+			By("reporting the evictions the budgets block")
+			carsPDB.Status.DisruptionsAllowed = 1
+			carsPDB.Status.CurrentHealthy, carsPDB.Status.DesiredHealthy, carsPDB.Status.ExpectedPods = 2, 1, 2
+			Expect(k8sClient.Status().Update(ctx, carsPDB)).To(Succeed())
+			mysqlPDB.Status.DisruptionsAllowed = 0
+			mysqlPDB.Status.CurrentHealthy, mysqlPDB.Status.DesiredHealthy, mysqlPDB.Status.ExpectedPods = 1, 1, 1
+			Expect(k8sClient.Status().Update(ctx, mysqlPDB)).To(Succeed())
+			_, err = controllerReconciler.ReconcileStatus(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			condition := apimeta.FindStatusCondition(instance.Status.Conditions, infrav1alpha1.ConditionDisruptionAllowed)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(infrav1alpha1.ReasonEvictionBlocked))
+			Expect(condition.Message).To(ContainSubstring(key.Name + "-mysql"))
+
+			By("pruning the budgets once disabled")
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			instance.Spec.Disruption.Disabled = true
+			Expect(k8sClient.Update(ctx, instance)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, carsKey(key), &policyv1.PodDisruptionBudget{}))).To(BeTrue())
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			Expect(apimeta.FindStatusCondition(instance.Status.Conditions, infrav1alpha1.ConditionDisruptionAllowed)).To(BeNil())
+		})
+	})
+
+	Context("When rendering the pods", func() {
+		ctx := context.Background()
+
+		It("should comply with the restricted pod security standard", func() {
+			namespace := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-restricted",
+					Labels: map[string]string{
+						"pod-security.kubernetes.io/enforce": "restricted",
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+			key := types.NamespacedName{Name: "test-security", Namespace: namespace.Name}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("admitting pods of both deployments in a restricted namespace")
+			for _, name := range []string{carsKey(key).Name, key.Name + "-mysql"} {
+				dep := &appsv1.Deployment{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: key.Namespace}, dep)).To(Succeed())
+				pod := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name + "-admission",
+						Namespace: key.Namespace,
+					},
+					Spec: dep.Spec.Template.Spec,
+				}
+				Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+				Expect(k8sClient.Delete(ctx, pod)).To(Succeed())
+			}
+
+			By("rejecting pods once the security context is overridden")
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			instance.Spec.SecurityContext = &corev1.SecurityContext{Privileged: ptr.To(true)}
+			Expect(k8sClient.Update(ctx, instance)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			Expect(*dep.Spec.Template.Spec.Containers[0].SecurityContext.Privileged).To(BeTrue())
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name + "-privileged",
+					Namespace: key.Namespace,
+				},
+				Spec: dep.Spec.Template.Spec,
+			}
+			Expect(k8sClient.Create(ctx, pod)).NotTo(Succeed())
+		})
+	})
+
+	Context("When configuring the ingress", func() {
+		ctx := context.Background()
+
+		It("should serve the configured hosts over plain HTTP with TLS disabled", func() {
+			key := types.NamespacedName{Name: "test-ingress", Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+				Ingress: infrav1alpha1.CarsIngressSpec{
+					ClassName: ptr.To("traefik"),
+					Hosts:     []string{"cars.example.com"},
+					Paths:     []infrav1alpha1.CarsIngressPath{{Path: "/"}},
+					TLS:       infrav1alpha1.CarsIngressTLSSpec{Disabled: true},
+				},
+			})
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			ingress := &networkingv1.Ingress{}
+			Expect(k8sClient.Get(ctx, carsKey(key), ingress)).To(Succeed())
+			Expect(*ingress.Spec.IngressClassName).To(Equal("traefik"))
+			Expect(ingress.Spec.TLS).To(BeEmpty())
+			Expect(ingress.Spec.Rules).To(HaveLen(1))
+			Expect(ingress.Spec.Rules[0].Host).To(Equal("cars.example.com"))
+			Expect(*ingress.Spec.Rules[0].HTTP.Paths[0].PathType).To(Equal(networkingv1.PathTypePrefix))
+
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			Expect(instance.Status.URL).To(Equal("http://cars.example.com"))
+			Expect(apimeta.FindStatusCondition(instance.Status.Conditions, infrav1alpha1.ConditionCertificateReady)).To(BeNil())
+		})
+	})
+
+	Context("When a child is no longer desired", func() {
+		ctx := context.Background()
+
+		removeDomain := func(key types.NamespacedName, pruneDryRun bool) error {
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+				Domain: "example.com",
+			})
+			controllerReconciler.PruneDryRun = pruneDryRun
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			ingress := &networkingv1.Ingress{}
+			Expect(k8sClient.Get(ctx, carsKey(key), ingress)).To(Succeed())
+			Expect(ingress.Labels).To(HaveKeyWithValue(infrav1alpha1.CarsLabel, key.Name))
+
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			instance.Spec.Domain = ""
+			Expect(k8sClient.Update(ctx, instance)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			return k8sClient.Get(ctx, carsKey(key), ingress)
+		}
+
+		It("should prune the ingress when the domain is cleared", func() {
+			err := removeDomain(types.NamespacedName{Name: "test-prune", Namespace: "default"}, false)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should only log the ingress to prune in dry-run mode", func() {
+			err := removeDomain(types.NamespacedName{Name: "test-prune-dry-run", Namespace: "default"}, true)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("When other controllers manage fields of a child", func() {
+		ctx := context.Background()
+
+		It("should only own the fields it renders and report conflicts", func() {
+			key := types.NamespacedName{Name: "test-ownership", Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("letting another controller annotate the pod template and change the image")
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			patch := client.MergeFrom(dep.DeepCopy())
+			dep.Spec.Template.Annotations = map[string]string{"sidecar.example.com/injected": "true"}
+			dep.Spec.Template.Spec.Containers[0].Image = "docker.io/galtbv/cars:other"
+			Expect(k8sClient.Patch(ctx, dep, patch, client.FieldOwner("other-controller"))).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			Expect(dep.Spec.Template.Annotations).To(HaveKeyWithValue("sidecar.example.com/injected", "true"))
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal(DefaultImage))
+
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			condition := apimeta.FindStatusCondition(instance.Status.Conditions, infrav1alpha1.ConditionFieldsOwned)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(infrav1alpha1.ReasonConflict))
+		})
+	})
+
+	Context("When observing a resource", func() {
+		ctx := context.Background()
+
+		It("should not wait on a certificate nothing issues", func() {
+			key := types.NamespacedName{Name: "test-no-issuer", Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+				Domain: "example.com",
+			})
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			Expect(apimeta.FindStatusCondition(instance.Status.Conditions, infrav1alpha1.ConditionCertificateReady)).To(BeNil())
+			Expect(instance.Status.URL).To(Equal("http://test-no-issuer.example.com"))
+			ingress := &networkingv1.Ingress{}
+			Expect(k8sClient.Get(ctx, carsKey(key), ingress)).To(Succeed())
+			Expect(ingress.Spec.TLS).To(BeEmpty())
+		})
+
+		It("should report component readiness in status", func() {
+			key := types.NamespacedName{Name: "test-status", Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+				Domain:        "example.com",
+				ClusterIssuer: "letsencrypt",
+			})
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).NotTo(BeZero())
+
+			By("checking the instance is pending while its children are not ready")
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			Expect(instance.Status.Phase).To(Equal(infrav1alpha1.CarsPhasePending))
+			Expect(instance.Status.ObservedGeneration).To(Equal(instance.Generation))
+			Expect(instance.Status.URL).To(Equal("https://test-status.example.com"))
+			Expect(apimeta.IsStatusConditionFalse(instance.Status.Conditions, infrav1alpha1.ConditionStorageBound)).To(BeTrue())
+			Expect(apimeta.IsStatusConditionFalse(instance.Status.Conditions, infrav1alpha1.ConditionAppAvailable)).To(BeTrue())
+
+			By("marking every child as ready")
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: key.Name + "-mysql-data", Namespace: key.Namespace}, pvc)).To(Succeed())
+			pvc.Status.Phase = corev1.ClaimBound
+			Expect(k8sClient.Status().Update(ctx, pvc)).To(Succeed())
+			for _, name := range []string{carsKey(key).Name, key.Name + "-mysql"} {
+				dep := &appsv1.Deployment{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: key.Namespace}, dep)).To(Succeed())
+				dep.Status.ObservedGeneration = dep.Generation
+				dep.Status.Replicas = 1
+				dep.Status.UpdatedReplicas = 1
+				dep.Status.ReadyReplicas = 1
+				dep.Status.AvailableReplicas = 1
+				Expect(k8sClient.Status().Update(ctx, dep)).To(Succeed())
+			}
+			ingress := &networkingv1.Ingress{}
+			Expect(k8sClient.Get(ctx, carsKey(key), ingress)).To(Succeed())
+			ingress.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{IP: "10.0.0.1"}}
+			Expect(k8sClient.Status().Update(ctx, ingress)).To(Succeed())
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name + "-tls",
+					Namespace: key.Namespace,
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					corev1.TLSCertKey:       []byte("cert"),
+					corev1.TLSPrivateKeyKey: []byte("key"),
+				},
+			})).To(Succeed())
+
+			result, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			Expect(instance.Status.Phase).To(Equal(infrav1alpha1.CarsPhaseRunning))
+			Expect(instance.Status.ReadyReplicas).To(Equal(int32(1)))
+			for _, condition := range []string{
+				infrav1alpha1.ConditionStorageBound,
+				infrav1alpha1.ConditionDatabaseReady,
+				infrav1alpha1.ConditionAppAvailable,
+				infrav1alpha1.ConditionIngressReady,
+				infrav1alpha1.ConditionCertificateReady,
+			} {
+				Expect(apimeta.IsStatusConditionTrue(instance.Status.Conditions, condition)).To(BeTrue(), condition)
+			}
+		})
+	})
+
+	Context("When a child changes", func() {
+		ctx := context.Background()
+
+		It("should tell spec drift apart from status changes", func() {
+			dep := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "drift", Namespace: "default", ResourceVersion: "1"},
+			}
+			statusChange := dep.DeepCopy()
+			statusChange.ResourceVersion = "2"
+			statusChange.Status.AvailableReplicas = 1
+			specChange := dep.DeepCopy()
+			specChange.ResourceVersion = "2"
+			specChange.Spec.Replicas = ptr.To(int32(2))
+
+			Expect(childSpecChanged().Update(event.UpdateEvent{ObjectOld: dep, ObjectNew: statusChange})).To(BeFalse())
+			Expect(childStatusChanged().Update(event.UpdateEvent{ObjectOld: dep, ObjectNew: statusChange})).To(BeTrue())
+			Expect(childSpecChanged().Update(event.UpdateEvent{ObjectOld: dep, ObjectNew: specChange})).To(BeTrue())
+			Expect(childStatusChanged().Update(event.UpdateEvent{ObjectOld: dep, ObjectNew: specChange})).To(BeFalse())
+			Expect(childSpecChanged().Delete(event.DeleteEvent{Object: dep})).To(BeTrue())
+			Expect(childSpecChanged().Create(event.CreateEvent{Object: dep})).To(BeFalse())
+		})
+
+		It("should refresh the status without re-rendering the children", func() {
+			key := types.NamespacedName{Name: "test-status-only", Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("drifting the service spec and making the app available")
+			service := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, carsKey(key), service)).To(Succeed())
+			service.Spec.Ports[0].Port = 8080
+			Expect(k8sClient.Update(ctx, service)).To(Succeed())
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, carsKey(key), dep)).To(Succeed())
+			dep.Status.ObservedGeneration = dep.Generation
+			dep.Status.Replicas = 1
+			dep.Status.AvailableReplicas = 1
+			Expect(k8sClient.Status().Update(ctx, dep)).To(Succeed())
+
+			_, err = controllerReconciler.ReconcileStatus(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			Expect(apimeta.IsStatusConditionTrue(instance.Status.Conditions, infrav1alpha1.ConditionAppAvailable)).To(BeTrue())
+			Expect(k8sClient.Get(ctx, carsKey(key), service)).To(Succeed())
+			Expect(service.Spec.Ports[0].Port).To(Equal(int32(8080)))
+
+			By("correcting the drift on a full reconcile")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, carsKey(key), service)).To(Succeed())
+			Expect(service.Spec.Ports[0].Port).To(Equal(int32(CarsPort)))
+		})
+
+		It("should adopt the mysql data of an earlier operator version", func() {
+			key := types.NamespacedName{Name: "test-legacy", Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{})
+			recorder := controllerReconciler.Recorder.(*record.FakeRecorder)
+
+			By("creating the children the way earlier versions named them")
+			legacyLabels := map[string]string{infrav1alpha1.CarsLabel: "true"}
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: LegacyMysqlPVCName, Namespace: key.Namespace, Labels: legacyLabels},
+				Spec:       *defaultPVCSpec(),
+			}
+			Expect(controllerutil.SetControllerReference(instance, pvc, k8sClient.Scheme())).To(Succeed())
+			Expect(k8sClient.Create(ctx, pvc)).To(Succeed())
+			podLabels := map[string]string{"app": "mysql", "deployment": "mysql"}
+			legacy := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: LegacyMysqlName, Namespace: key.Namespace, Labels: legacyLabels},
+				Spec: appsv1.DeploymentSpec{
+					Selector: metav1.SetAsLabelSelector(podLabels),
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "mysql", Image: MysqlImage}},
+						},
+					},
+				},
+			}
+			Expect(controllerutil.SetControllerReference(instance, legacy, k8sClient.Scheme())).To(Succeed())
+			Expect(k8sClient.Create(ctx, legacy)).To(Succeed())
+
+			By("waiting for the legacy mysql deployment to go before mounting its data")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			Expect(instance.Status.MysqlPVC).To(Equal(LegacyMysqlPVCName))
+			condition := apimeta.FindStatusCondition(instance.Status.Conditions, infrav1alpha1.ConditionReconciled)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Reason).To(Equal(infrav1alpha1.ReconciledReasonWaiting))
+			Expect(instance.Status.Phase).To(Equal(infrav1alpha1.CarsPhasePending))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(legacy), legacy)).To(Succeed())
+			Expect(legacy.DeletionTimestamp.IsZero()).To(BeFalse())
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: key.Name + "-mysql", Namespace: key.Namespace}, &appsv1.Deployment{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: key.Name + "-mysql-data", Namespace: key.Namespace}, &corev1.PersistentVolumeClaim{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(drainEvents(recorder)).To(ContainElement(ContainSubstring(EventReasonDataAdopted)))
+
+			By("finishing the foreground deletion, there is no garbage collector in envtest")
+			legacy.Finalizers = nil
+			Expect(k8sClient.Update(ctx, legacy)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			mysql := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: key.Name + "-mysql", Namespace: key.Namespace}, mysql)).To(Succeed())
+			Expect(mysql.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal(LegacyMysqlPVCName))
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: key.Name + "-mysql-data", Namespace: key.Namespace}, &corev1.PersistentVolumeClaim{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Context("When deleting a resource", func() {
+		ctx := context.Background()
+
+		// createAndReconcile creates a Cars instance with the given retention policy and renders its children
+		createAndReconcile := func(name string, policy infrav1alpha1.RetentionPolicy) (*CarsReconciler, types.NamespacedName) {
+			key := types.NamespacedName{Name: name, Namespace: "default"}
+			instance, controllerReconciler := createCars(ctx, key, infrav1alpha1.CarsSpec{
+				Domain: "example.com",
+				Storage: infrav1alpha1.CarsStorageSpec{
+					RetentionPolicy: policy,
+				},
+			})
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			Expect(instance.Finalizers).To(ContainElement(infrav1alpha1.CarsFinalizer))
+			Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+			return controllerReconciler, key
+		}
+
+		It("should orphan the mysql PVC with the Retain policy", func() {
+			controllerReconciler, key := createAndReconcile("test-retain", infrav1alpha1.RetentionPolicyRetain)
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: key.Name + "-mysql-data", Namespace: key.Namespace}, pvc)).To(Succeed())
+			Expect(pvc.OwnerReferences).To(BeEmpty())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, key, &infrav1alpha1.Cars{}))).To(BeTrue())
+		})
+
+		It("should delete the mysql PVC with the Delete policy", func() {
+			controllerReconciler, key := createAndReconcile("test-delete", infrav1alpha1.RetentionPolicyDelete)
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			pvc := &corev1.PersistentVolumeClaim{}
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: key.Name + "-mysql-data", Namespace: key.Namespace}, pvc)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, key, &infrav1alpha1.Cars{}))).To(BeTrue())
+		})
+
+		It("should not start the backup when the mysql deployment is gone", func() {
+			controllerReconciler, key := createAndReconcile("test-backup-gone", infrav1alpha1.RetentionPolicyBackupThenDelete)
+			mysql := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: key.Name + "-mysql", Namespace: key.Namespace}}
+			Expect(k8sClient.Delete(ctx, mysql)).To(Succeed())
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).NotTo(BeZero())
+
+			backupKey := types.NamespacedName{Name: key.Name + "-mysql-backup", Namespace: key.Namespace}
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, backupKey, &batchv1.Job{}))).To(BeTrue())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: key.Name + "-mysql-data", Namespace: key.Namespace}, &corev1.PersistentVolumeClaim{})).To(Succeed())
+			recorder := controllerReconciler.Recorder.(*record.FakeRecorder)
+			Expect(drainEvents(recorder)).To(ContainElement(ContainSubstring("Warning BackupFailed Cannot back up the database")))
+
+			By("changing the retention policy to proceed")
+			instance := &infrav1alpha1.Cars{}
+			Expect(k8sClient.Get(ctx, key, instance)).To(Succeed())
+			instance.Spec.Storage.RetentionPolicy = infrav1alpha1.RetentionPolicyRetain
+			Expect(k8sClient.Update(ctx, instance)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, key, &infrav1alpha1.Cars{}))).To(BeTrue())
+		})
+
+		It("should back up the database before deleting the mysql PVC with the BackupThenDelete policy", func() {
+			controllerReconciler, key := createAndReconcile("test-backup", infrav1alpha1.RetentionPolicyBackupThenDelete)
+
+			By("marking the mysql deployment available, there is no deployment controller in envtest")
+			mysql := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: key.Name + "-mysql", Namespace: key.Namespace}, mysql)).To(Succeed())
+			mysql.Status.Replicas = 1
+			mysql.Status.UpdatedReplicas = 1
+			mysql.Status.ReadyReplicas = 1
+			mysql.Status.AvailableReplicas = 1
+			Expect(k8sClient.Status().Update(ctx, mysql)).To(Succeed())
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).NotTo(BeZero())
+
+			By("checking the backup job and PVC were created and the data is kept")
+			backupKey := types.NamespacedName{Name: key.Name + "-mysql-backup", Namespace: key.Namespace}
+			backupPVC := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, backupKey, backupPVC)).To(Succeed())
+			Expect(backupPVC.OwnerReferences).To(BeEmpty())
+			job := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, backupKey, job)).To(Succeed())
+			Expect(k8sClient.Get(ctx, key, &infrav1alpha1.Cars{})).To(Succeed())
+
+			By("completing the backup job")
+			job.Status.Succeeded = 1
+			Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			pvc := &corev1.PersistentVolumeClaim{}
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: key.Name + "-mysql-data", Namespace: key.Namespace}, pvc)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, key, &infrav1alpha1.Cars{}))).To(BeTrue())
+		})
 	})
 })
@@ -1,8 +1,10 @@
 package controller
 
 import (
+	"path"
+
 	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
-	"github.com/go-logr/logr"
+	"github.com/bitcoin-sv/cars-operator/internal/utils"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -12,56 +14,80 @@ import (
 )
 
 // ReconcileDeployment is the cars deployment reconciler
-func (r *CarsReconciler) ReconcileDeployment(log logr.Logger) (bool, error) {
-	cars := infrav1alpha1.Cars{}
-	if err := r.Get(r.Context, r.NamespacedName, &cars); err != nil {
-		return false, err
+func (r *CarsReconciler) ReconcileDeployment(scope *reconcileScope) (utils.StepResult, error) {
+	cars := scope.Cars
+	dep := renderCarsDeployment(withResolvedImages(scope))
+	if cars.Spec.Autoscaling != nil {
+		replicas, err := r.autoscaledReplicas(scope, dep.Name)
+		if err != nil {
+			return utils.StepFailed, err
+		}
+		dep.Spec.Replicas = replicas
 	}
-	dep := appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "cars",
-			Namespace: r.NamespacedName.Namespace,
-			Labels:    getAppLabels(),
-		},
-	}
-	_, err := controllerutil.CreateOrUpdate(r.Context, r.Client, &dep, func() error {
-		return r.updateDeployment(&dep, &cars)
+	op, err := r.apply(scope, dep, func() error {
+		return controllerutil.SetControllerReference(cars, dep, r.Scheme)
 	})
+	r.recordResult(cars, "Deployment", dep.Name, op, err)
 	if err != nil {
-		return false, err
+		return utils.StepFailed, err
 	}
-	return true, nil
+	return utils.StepCompleted, nil
 }
 
-func (r *CarsReconciler) updateDeployment(dep *appsv1.Deployment, cars *infrav1alpha1.Cars) error {
-	err := controllerutil.SetControllerReference(cars, dep, r.Scheme)
-	if err != nil {
-		return err
+// renderCarsDeployment renders the cars deployment, leaving the owner reference to the reconciler
+func renderCarsDeployment(cars *infrav1alpha1.Cars) *appsv1.Deployment {
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      carsName(cars),
+			Namespace: cars.Namespace,
+			Labels:    getAppLabels(cars),
+		},
+		Spec: *defaultCarsDeploymentSpec(cars),
 	}
-	dep.Spec = *defaultCarsDeploymentSpec()
 
-	if cars.Spec.Image != "" {
-		dep.Spec.Template.Spec.Containers[0].Image = cars.Spec.Image
+	container := &dep.Spec.Template.Spec.Containers[0]
+	container.EnvFrom = networkEnvFrom(cars, envFromOrDefault(cars.Spec.EnvFrom, container.EnvFrom))
+	container.Env = mergeEnv(append(container.Env, networkEnv(cars)...), cars.Spec.Env)
+	container.StartupProbe = overrideProbe(defaultCarsStartupProbe(cars), cars.Spec.Probes.Startup)
+	container.LivenessProbe = overrideProbe(defaultCarsLivenessProbe(cars), cars.Spec.Probes.Liveness)
+	container.ReadinessProbe = overrideProbe(defaultCarsReadinessProbe(cars), cars.Spec.Probes.Readiness)
+	applyScheduling(&dep.Spec.Template.Spec, cars.Spec.Scheduling, defaultCarsAffinity(cars))
+	applySecurityContext(&dep.Spec.Template.Spec, cars.Spec.PodSecurityContext, cars.Spec.SecurityContext, CarsUser)
+	mountWritableDirs(&dep.Spec.Template.Spec, carsWritableDirs)
+	if cars.Spec.Resources != nil {
+		container.Resources = *cars.Spec.Resources.DeepCopy()
+	}
+	if cars.Spec.Autoscaling != nil {
+		// Leave the replicas to the HorizontalPodAutoscaler, ReconcileDeployment keeps the live value until it owns them
+		dep.Spec.Replicas = nil
+	} else if cars.Spec.Replicas != nil {
+		dep.Spec.Replicas = ptr.To(*cars.Spec.Replicas)
 	}
 
-	return nil
+	// The overlay goes last so it wins over the spec fields. One failing to apply is rejected by ValidateSpec
+	_ = applyPodTemplate(&dep.Spec.Template, cars.Spec.PodTemplate)
+	secureOverlaidContainers(&dep.Spec.Template.Spec, cars.Spec.SecurityContext)
+
+	return dep
 }
 
-func defaultCarsDeploymentSpec() *appsv1.DeploymentSpec {
-	labels := map[string]string{
-		"app":        "cars",
-		"deployment": "cars",
-	}
+func defaultCarsDeploymentSpec(cars *infrav1alpha1.Cars) *appsv1.DeploymentSpec {
+	labels := selectorLabels(cars, ComponentCars)
 	envFrom := []corev1.EnvFromSource{
 		{
 			SecretRef: &corev1.SecretEnvSource{
 				LocalObjectReference: corev1.LocalObjectReference{
-					Name: "cars-environment",
+					Name: CarsEnvironmentSecret,
 				},
 			},
 		},
 	}
-	env := []corev1.EnvVar{}
+	env := []corev1.EnvVar{
+		{
+			Name:  "CARS_CONFIG_FILE",
+			Value: path.Join(CarsConfigDir, CarsConfigFile),
+		},
+	}
 	return &appsv1.DeploymentSpec{
 		Replicas: ptr.To(int32(1)),
 		Selector: metav1.SetAsLabelSelector(labels),
@@ -69,17 +95,21 @@ func defaultCarsDeploymentSpec() *appsv1.DeploymentSpec {
 			ObjectMeta: metav1.ObjectMeta{
 				CreationTimestamp: metav1.Time{},
 				Labels:            labels,
+				Annotations: map[string]string{
+					infrav1alpha1.ConfigHashAnnotation: carsConfigHash(cars),
+				},
 			},
 			Spec: corev1.PodSpec{
-				ServiceAccountName: DefaultServiceAccount,
+				ServiceAccountName: carsServiceAccountName(cars),
+				ImagePullSecrets:   imagePullSecrets(cars),
 				Containers: []corev1.Container{
 					{
 						EnvFrom:         envFrom,
 						Env:             env,
-						Image:           DefaultImage,
-						ImagePullPolicy: corev1.PullAlways,
+						Image:           carsImage(cars),
+						ImagePullPolicy: imagePullPolicy(cars),
 						Name:            "cars",
-						// Make sane defaults, and this should be configurable
+						// Sane defaults, replaced by spec.resources
 						Resources: corev1.ResourceRequirements{
 							Limits: corev1.ResourceList{
 								corev1.ResourceMemory: resource.MustParse("500Mi"),
@@ -91,23 +121,30 @@ func defaultCarsDeploymentSpec() *appsv1.DeploymentSpec {
 						},
 						Ports: []corev1.ContainerPort{
 							{
-								ContainerPort: CarsPort,
+								ContainerPort: carsListenPort(cars),
 								Protocol:      corev1.ProtocolTCP,
 							},
 						},
+						VolumeMounts: []corev1.VolumeMount{
+							{
+								MountPath: CarsConfigDir,
+								Name:      "cars-config",
+								ReadOnly:  true,
+							},
+						},
 					},
 				},
 				Volumes: []corev1.Volume{
-					/*{
+					{
 						Name: "cars-config",
 						VolumeSource: corev1.VolumeSource{
 							ConfigMap: &corev1.ConfigMapVolumeSource{
 								LocalObjectReference: corev1.LocalObjectReference{
-									Name: "cars-config",
+									Name: carsConfigName(cars),
 								},
 							},
 						},
-					},*/
+					},
 				},
 			},
 		},
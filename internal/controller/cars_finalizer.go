@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// reconcileDelete applies the storage retention policy of a Cars instance being deleted and releases the finalizer once done
+func (r *CarsReconciler) reconcileDelete(scope *reconcileScope) (ctrl.Result, error) {
+	cars := scope.Cars
+	if !controllerutil.ContainsFinalizer(cars, infrav1alpha1.CarsFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	done, err := r.finalize(scope)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !done {
+		scope.Log.Info("waiting for storage retention policy to complete", "policy", retentionPolicy(cars))
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	}
+	controllerutil.RemoveFinalizer(cars, infrav1alpha1.CarsFinalizer)
+	return ctrl.Result{}, r.Update(scope.Context, cars)
+}
+
+// finalize returns true once the mysql data PVC has been handled according to the retention policy
+func (r *CarsReconciler) finalize(scope *reconcileScope) (bool, error) {
+	cars := scope.Cars
+	pvc := corev1.PersistentVolumeClaim{}
+	err := r.Get(scope.Context, types.NamespacedName{Namespace: cars.Namespace, Name: mysqlPVCName(cars)}, &pvc)
+	if k8serrors.IsNotFound(err) {
+		// Nothing left to retain or delete
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch policy := retentionPolicy(cars); policy {
+	case infrav1alpha1.RetentionPolicyRetain:
+		// Orphan the PVC so garbage collection leaves it behind
+		if err := controllerutil.RemoveControllerReference(cars, &pvc, r.Scheme); err != nil {
+			// Not owned by this instance, so it is already safe from garbage collection
+			return true, nil
+		}
+		if err := r.Update(scope.Context, &pvc); err != nil {
+			return false, err
+		}
+		r.Recorder.Eventf(cars, corev1.EventTypeNormal, EventReasonDataRetained, "Retained PersistentVolumeClaim %s", pvc.Name)
+		return true, nil
+	case infrav1alpha1.RetentionPolicyDelete:
+		return r.deleteMysqlData(scope, &pvc)
+	case infrav1alpha1.RetentionPolicyBackupThenDelete:
+		done, err := r.backupMysql(scope, &pvc)
+		if !done || err != nil {
+			return false, err
+		}
+		return r.deleteMysqlData(scope, &pvc)
+	default:
+		return false, fmt.Errorf("unknown retention policy %q", policy)
+	}
+}
+
+func (r *CarsReconciler) deleteMysqlData(scope *reconcileScope, pvc *corev1.PersistentVolumeClaim) (bool, error) {
+	if err := client.IgnoreNotFound(r.Delete(scope.Context, pvc)); err != nil {
+		return false, err
+	}
+	r.Recorder.Eventf(scope.Cars, corev1.EventTypeNormal, EventReasonDataDeleted, "Deleted PersistentVolumeClaim %s", pvc.Name)
+	return true, nil
+}
+
+// retentionPolicy returns the configured retention policy, defaulting to Retain
+func retentionPolicy(cars *infrav1alpha1.Cars) infrav1alpha1.RetentionPolicy {
+	if cars.Spec.Storage.RetentionPolicy == "" {
+		return infrav1alpha1.RetentionPolicyRetain
+	}
+	return cars.Spec.Storage.RetentionPolicy
+}
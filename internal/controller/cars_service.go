@@ -1,51 +1,94 @@
 package controller
 
 import (
-	"fmt"
 	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
-	"github.com/go-logr/logr"
+	"github.com/bitcoin-sv/cars-operator/internal/utils"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 // ReconcileService is the cars service reconciler
-func (r *CarsReconciler) ReconcileService(log logr.Logger) (bool, error) {
-	cars := infrav1alpha1.Cars{}
-	if err := r.Get(r.Context, r.NamespacedName, &cars); err != nil {
-		return false, err
+func (r *CarsReconciler) ReconcileService(scope *reconcileScope) (utils.StepResult, error) {
+	cars := scope.Cars
+	svc := renderCarsService(cars)
+	replacing, err := r.replaceHeadlessService(scope, svc)
+	if err != nil {
+		r.recordResult(cars, "Service", svc.Name, controllerutil.OperationResultNone, err)
+		return utils.StepFailed, err
 	}
-	svc := corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "cars",
-			Namespace: r.NamespacedName.Namespace,
-			Labels:    getAppLabels(),
-		},
+	if replacing {
+		return utils.StepStopped, nil
 	}
-	_, err := controllerutil.CreateOrUpdate(r.Context, r.Client, &svc, func() error {
-		return r.updateService(&svc, &cars)
+	op, err := r.apply(scope, svc, func() error {
+		return controllerutil.SetControllerReference(cars, svc, r.Scheme)
 	})
+	r.recordResult(cars, "Service", svc.Name, op, err)
 	if err != nil {
-		return false, err
+		return utils.StepFailed, err
 	}
-	return true, nil
+	return utils.StepCompleted, nil
 }
 
-func (r *CarsReconciler) updateService(svc *corev1.Service, cars *infrav1alpha1.Cars) error {
-	err := controllerutil.SetControllerReference(cars, svc, r.Scheme)
-	if err != nil {
-		return err
+// replaceHeadlessService deletes the existing service when it is headless and the rendered one is not, or the other
+// way around, as the cluster IP of a service cannot change. It returns true until the existing service is gone,
+// which takes a while for a load balancer
+func (r *CarsReconciler) replaceHeadlessService(scope *reconcileScope, svc *corev1.Service) (bool, error) {
+	existing := corev1.Service{}
+	found, err := r.getChild(scope, svc.Name, &existing)
+	if err != nil || !found || !metav1.IsControlledBy(&existing, scope.Cars) {
+		return false, err
 	}
-	svc.Spec = *defaultCarsServiceSpec()
-	return nil
+	if !existing.DeletionTimestamp.IsZero() {
+		return true, nil
+	}
+	headless := existing.Spec.ClusterIP == corev1.ClusterIPNone
+	if headless == (svc.Spec.ClusterIP == corev1.ClusterIPNone) {
+		return false, nil
+	}
+	scope.Log.Info("recreating service to change its cluster IP", "service", svc.Name, "headless", !headless)
+	err = r.Delete(scope.Context, &existing, client.Preconditions{UID: &existing.UID})
+	return true, client.IgnoreNotFound(err)
 }
 
-func defaultCarsServiceSpec() *corev1.ServiceSpec {
-	labels := map[string]string{
-		"app": "cars",
+// renderCarsService renders the cars service, leaving the owner reference to the reconciler
+func renderCarsService(cars *infrav1alpha1.Cars) *corev1.Service {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      carsName(cars),
+			Namespace: cars.Namespace,
+			Labels:    getAppLabels(cars),
+		},
+		Spec: *defaultCarsServiceSpec(cars),
+	}
+
+	spec := cars.Spec.Service
+	if len(spec.Annotations) > 0 {
+		svc.Annotations = make(map[string]string, len(spec.Annotations))
+		for key, value := range spec.Annotations {
+			svc.Annotations[key] = value
+		}
+	}
+	if spec.Type != "" && spec.Type != corev1.ServiceTypeClusterIP {
+		// Only a ClusterIP service can be headless, the cluster allocates the IP of the others
+		svc.Spec.Type = spec.Type
+		svc.Spec.ClusterIP = ""
 	}
+	if spec.IPFamilyPolicy != nil && *spec.IPFamilyPolicy != corev1.IPFamilyPolicySingleStack {
+		svc.Spec.IPFamilyPolicy = ptr.To(*spec.IPFamilyPolicy)
+		svc.Spec.IPFamilies = nil
+	}
+	svc.Spec.LoadBalancerSourceRanges = append([]string(nil), spec.LoadBalancerSourceRanges...)
+	svc.Spec.ExternalTrafficPolicy = spec.ExternalTrafficPolicy
+	return svc
+}
+
+func defaultCarsServiceSpec(cars *infrav1alpha1.Cars) *corev1.ServiceSpec {
+	labels := selectorLabels(cars, ComponentCars)
 	ipFamily := corev1.IPFamilyPolicySingleStack
 	return &corev1.ServiceSpec{
 		Selector:       labels,
@@ -58,7 +101,7 @@ func defaultCarsServiceSpec() *corev1.ServiceSpec {
 			{
 				Name:       "cars-tcp",
 				Port:       int32(CarsPort),
-				TargetPort: intstr.FromInt32(CarsPort),
+				TargetPort: intstr.FromInt32(carsListenPort(cars)),
 				Protocol:   corev1.ProtocolTCP,
 			},
 		},
@@ -66,80 +109,120 @@ func defaultCarsServiceSpec() *corev1.ServiceSpec {
 }
 
 // ReconcileIngress is the ingress
-func (r *CarsReconciler) ReconcileIngress(log logr.Logger) (bool, error) {
-	cars := infrav1alpha1.Cars{}
-	if err := r.Get(r.Context, r.NamespacedName, &cars); err != nil {
-		return false, err
-	}
-	// Skip if domain isn't set
-	if cars.Spec.Domain == "" {
-		return false, nil
-	}
-	ingress := networkingv1.Ingress{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "cars",
-			Namespace: r.NamespacedName.Namespace,
-			Labels:    getAppLabels(),
-		},
+func (r *CarsReconciler) ReconcileIngress(scope *reconcileScope) (utils.StepResult, error) {
+	cars := scope.Cars
+	// Skip if neither a domain nor hosts are set, an ingress rendered before is pruned
+	if !ingressEnabled(cars) {
+		return utils.StepSkipped, nil
 	}
-	_, err := controllerutil.CreateOrUpdate(r.Context, r.Client, &ingress, func() error {
-		return r.updateIngress(&ingress, &cars)
+	ingress := renderCarsIngress(cars)
+	op, err := r.apply(scope, ingress, func() error {
+		return controllerutil.SetControllerReference(cars, ingress, r.Scheme)
 	})
+	r.recordResult(cars, "Ingress", ingress.Name, op, err)
 	if err != nil {
-		return false, err
+		return utils.StepFailed, err
 	}
-	return true, nil
+	return utils.StepCompleted, nil
 }
 
-func (r *CarsReconciler) updateIngress(ingress *networkingv1.Ingress, cars *infrav1alpha1.Cars) error {
-	err := controllerutil.SetControllerReference(cars, ingress, r.Scheme)
-	if err != nil {
-		return err
+// ingressEnabled returns whether the cars app is exposed through an ingress
+func ingressEnabled(cars *infrav1alpha1.Cars) bool {
+	return cars.Spec.Domain != "" || len(cars.Spec.Ingress.Hosts) > 0
+}
+
+// ingressTLSEnabled returns whether the ingress terminates TLS for its hosts. It defaults on only when something
+// provides the certificate, as the ingress would otherwise reference a secret nobody writes
+func ingressTLSEnabled(cars *infrav1alpha1.Cars) bool {
+	return !cars.Spec.Ingress.TLS.Disabled && certificateExpected(cars)
+}
+
+// certificateExpected returns whether something provides the TLS secret of the ingress: cert-manager for the cluster
+// issuer, or the user under spec.ingress.tls.secretName
+func certificateExpected(cars *infrav1alpha1.Cars) bool {
+	return cars.Spec.ClusterIssuer != "" || cars.Spec.Ingress.TLS.SecretName != ""
+}
+
+// renderCarsIngress renders the cars ingress, leaving the owner reference to the reconciler
+func renderCarsIngress(cars *infrav1alpha1.Cars) *networkingv1.Ingress {
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      carsName(cars),
+			Namespace: cars.Namespace,
+			Labels:    getAppLabels(cars),
+		},
+		Spec: *defaultCarsIngressSpec(cars),
 	}
-	if cars.Spec.ClusterIssuer != "" {
-		if ingress.Annotations == nil {
-			ingress.Annotations = make(map[string]string)
-		}
-		ingress.Annotations["cert-manager.io/cluster-issuer"] = cars.Spec.ClusterIssuer
+
+	annotations := map[string]string{}
+	if cars.Spec.ClusterIssuer != "" && ingressTLSEnabled(cars) {
+		annotations["cert-manager.io/cluster-issuer"] = cars.Spec.ClusterIssuer
+	}
+	for key, value := range cars.Spec.Ingress.Annotations {
+		annotations[key] = value
+	}
+	if len(annotations) > 0 {
+		ingress.Annotations = annotations
 	}
-	ingress.Spec = *defaultCarsIngressSpec(cars)
-	return nil
+	if cars.Spec.Ingress.ClassName != nil {
+		ingress.Spec.IngressClassName = ptr.To(*cars.Spec.Ingress.ClassName)
+	}
+	if !ingressTLSEnabled(cars) {
+		ingress.Spec.TLS = nil
+	}
+	return ingress
 }
 
 func defaultCarsIngressSpec(cars *infrav1alpha1.Cars) *networkingv1.IngressSpec {
-	pathType := networkingv1.PathTypeImplementationSpecific
 	class := "nginx"
+	backend := networkingv1.IngressBackend{
+		Service: &networkingv1.IngressServiceBackend{
+			Name: carsName(cars),
+			Port: networkingv1.ServiceBackendPort{
+				Number: int32(CarsPort),
+			},
+		},
+	}
+	paths := []networkingv1.HTTPIngressPath{
+		{
+			PathType: ptr.To(networkingv1.PathTypeImplementationSpecific),
+			Backend:  backend,
+		},
+	}
+	if len(cars.Spec.Ingress.Paths) > 0 {
+		paths = make([]networkingv1.HTTPIngressPath, 0, len(cars.Spec.Ingress.Paths))
+		for _, path := range cars.Spec.Ingress.Paths {
+			pathType := networkingv1.PathTypePrefix
+			if path.PathType != nil {
+				pathType = *path.PathType
+			}
+			paths = append(paths, networkingv1.HTTPIngressPath{
+				Path:     path.Path,
+				PathType: ptr.To(pathType),
+				Backend:  *backend.DeepCopy(),
+			})
+		}
+	}
+	hosts := carsHosts(cars)
+	rules := make([]networkingv1.IngressRule, 0, len(hosts))
+	for _, host := range hosts {
+		rules = append(rules, networkingv1.IngressRule{
+			Host: host,
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: append([]networkingv1.HTTPIngressPath{}, paths...),
+				},
+			},
+		})
+	}
 	return &networkingv1.IngressSpec{
 		IngressClassName: &class,
 		TLS: []networkingv1.IngressTLS{
 			{
-				Hosts: []string{
-					fmt.Sprintf("%s.%s", cars.Name, cars.Spec.Domain),
-				},
-				SecretName: "cars-tls",
-			},
-		},
-		Rules: []networkingv1.IngressRule{
-			{
-				Host: fmt.Sprintf("%s.%s", cars.Name, cars.Spec.Domain),
-				IngressRuleValue: networkingv1.IngressRuleValue{
-					HTTP: &networkingv1.HTTPIngressRuleValue{
-						Paths: []networkingv1.HTTPIngressPath{
-							{
-								PathType: &pathType,
-								Backend: networkingv1.IngressBackend{
-									Service: &networkingv1.IngressServiceBackend{
-										Name: "cars",
-										Port: networkingv1.ServiceBackendPort{
-											Number: int32(CarsPort),
-										},
-									},
-								},
-							},
-						},
-					},
-				},
+				Hosts:      hosts,
+				SecretName: carsTLSSecretName(cars),
 			},
 		},
+		Rules: rules,
 	}
 }
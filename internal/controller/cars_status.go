@@ -0,0 +1,236 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ReconcileStatus refreshes the status of a Cars instance after the status of one of its children changed,
+// without rendering and applying the children again
+func (r *CarsReconciler) ReconcileStatus(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	cars := infrav1alpha1.Cars{}
+	if err := r.Get(ctx, req.NamespacedName, &cars); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// Pending spec changes and deletions are handled by Reconcile, which observes the status itself
+	if !cars.DeletionTimestamp.IsZero() || cars.Status.ObservedGeneration != cars.Generation {
+		return ctrl.Result{}, nil
+	}
+	scope := &reconcileScope{
+		Context: ctx,
+		Log:     log.FromContext(ctx).WithValues("cars", req.NamespacedName),
+		Cars:    &cars,
+	}
+	observed := cars.Status.DeepCopy()
+	if err := r.observeStatus(scope); err != nil {
+		return ctrl.Result{}, err
+	}
+	if !equality.Semantic.DeepEqual(observed, &cars.Status) {
+		if err := r.Status().Update(ctx, &cars); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return certificateRequeue(&cars), nil
+}
+
+// certificateRequeue polls for the TLS secret while it is not issued, as it is written by cert-manager and not watched
+func certificateRequeue(cars *infrav1alpha1.Cars) ctrl.Result {
+	if apimeta.IsStatusConditionFalse(cars.Status.Conditions, infrav1alpha1.ConditionCertificateReady) {
+		return ctrl.Result{RequeueAfter: 10 * time.Second}
+	}
+	return ctrl.Result{}
+}
+
+// observeStatus computes the component conditions, phase and URL of a Cars instance from its children
+func (r *CarsReconciler) observeStatus(scope *reconcileScope) error {
+	cars := scope.Cars
+	if err := r.observeStorage(scope); err != nil {
+		return err
+	}
+	if _, err := r.observeDeployment(scope, mysqlName(cars), infrav1alpha1.ConditionDatabaseReady); err != nil {
+		return err
+	}
+	app, err := r.observeDeployment(scope, carsName(cars), infrav1alpha1.ConditionAppAvailable)
+	if err != nil {
+		return err
+	}
+	cars.Status.Replicas, cars.Status.ReadyReplicas = 0, 0
+	if app != nil {
+		cars.Status.Replicas = app.Status.Replicas
+		cars.Status.ReadyReplicas = app.Status.ReadyReplicas
+	}
+	if err := r.observeDisruption(scope); err != nil {
+		return err
+	}
+	if !ingressEnabled(cars) {
+		apimeta.RemoveStatusCondition(&cars.Status.Conditions, infrav1alpha1.ConditionIngressReady)
+		apimeta.RemoveStatusCondition(&cars.Status.Conditions, infrav1alpha1.ConditionCertificateReady)
+		cars.Status.URL = ""
+	} else {
+		if err := r.observeIngress(scope); err != nil {
+			return err
+		}
+		scheme := "http"
+		if ingressTLSEnabled(cars) {
+			if err := r.observeCertificate(scope); err != nil {
+				return err
+			}
+			scheme = "https"
+		} else {
+			apimeta.RemoveStatusCondition(&cars.Status.Conditions, infrav1alpha1.ConditionCertificateReady)
+		}
+		cars.Status.URL = fmt.Sprintf("%s://%s", scheme, carsHosts(cars)[0])
+	}
+	cars.Status.ObservedGeneration = cars.Generation
+	cars.Status.Phase = summarizePhase(cars)
+	return nil
+}
+
+func (r *CarsReconciler) observeStorage(scope *reconcileScope) error {
+	cars := scope.Cars
+	pvc := corev1.PersistentVolumeClaim{}
+	found, err := r.getChild(scope, mysqlPVCName(cars), &pvc)
+	if err != nil {
+		return err
+	}
+	if !found {
+		setComponentCondition(cars, infrav1alpha1.ConditionStorageBound, false, infrav1alpha1.ReasonNotFound, "mysql data PVC not found")
+		return nil
+	}
+	phase := pvc.Status.Phase
+	if phase == "" {
+		phase = corev1.ClaimPending
+	}
+	setComponentCondition(cars, infrav1alpha1.ConditionStorageBound, phase == corev1.ClaimBound, string(phase),
+		fmt.Sprintf("PVC %s is %s", pvc.Name, phase))
+	return nil
+}
+
+// observeDeployment sets the condition of a deployment from its available replicas, which only count pods passing
+// their readiness probe. It returns the deployment, or nil when it does not exist
+func (r *CarsReconciler) observeDeployment(scope *reconcileScope, name string, conditionType string) (*appsv1.Deployment, error) {
+	cars := scope.Cars
+	dep := appsv1.Deployment{}
+	found, err := r.getChild(scope, name, &dep)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		setComponentCondition(cars, conditionType, false, infrav1alpha1.ReasonNotFound, fmt.Sprintf("deployment %s not found", name))
+		return nil, nil
+	}
+	desired := int32(1)
+	if dep.Spec.Replicas != nil {
+		desired = *dep.Spec.Replicas
+	}
+	available := dep.Status.ObservedGeneration >= dep.Generation && dep.Status.AvailableReplicas >= desired && desired > 0
+	reason := infrav1alpha1.ReasonUnavailable
+	if available {
+		reason = infrav1alpha1.ReasonAvailable
+	}
+	setComponentCondition(cars, conditionType, available, reason,
+		fmt.Sprintf("%d/%d replicas available, %d ready", dep.Status.AvailableReplicas, desired, dep.Status.ReadyReplicas))
+	return &dep, nil
+}
+
+func (r *CarsReconciler) observeIngress(scope *reconcileScope) error {
+	cars := scope.Cars
+	ingress := networkingv1.Ingress{}
+	found, err := r.getChild(scope, carsName(cars), &ingress)
+	if err != nil {
+		return err
+	}
+	if !found {
+		setComponentCondition(cars, infrav1alpha1.ConditionIngressReady, false, infrav1alpha1.ReasonNotFound, "ingress not found")
+		return nil
+	}
+	if len(ingress.Status.LoadBalancer.Ingress) == 0 {
+		setComponentCondition(cars, infrav1alpha1.ConditionIngressReady, false, infrav1alpha1.ReasonAddressPending, "waiting for an ingress address")
+		return nil
+	}
+	address := ingress.Status.LoadBalancer.Ingress[0].IP
+	if address == "" {
+		address = ingress.Status.LoadBalancer.Ingress[0].Hostname
+	}
+	setComponentCondition(cars, infrav1alpha1.ConditionIngressReady, true, infrav1alpha1.ReasonAddressAssigned,
+		fmt.Sprintf("ingress is served at %s", address))
+	return nil
+}
+
+func (r *CarsReconciler) observeCertificate(scope *reconcileScope) error {
+	cars := scope.Cars
+	secret := corev1.Secret{}
+	found, err := r.getChild(scope, carsTLSSecretName(cars), &secret)
+	if err != nil {
+		return err
+	}
+	if !found || len(secret.Data[corev1.TLSCertKey]) == 0 {
+		setComponentCondition(cars, infrav1alpha1.ConditionCertificateReady, false, infrav1alpha1.ReasonCertificatePending,
+			fmt.Sprintf("waiting for TLS secret %s", carsTLSSecretName(cars)))
+		return nil
+	}
+	setComponentCondition(cars, infrav1alpha1.ConditionCertificateReady, true, infrav1alpha1.ReasonCertificateIssued,
+		fmt.Sprintf("TLS secret %s is issued", secret.Name))
+	return nil
+}
+
+// getChild fetches a child of the Cars instance by name, returning false if it does not exist
+func (r *CarsReconciler) getChild(scope *reconcileScope, name string, obj client.Object) (bool, error) {
+	err := r.Get(scope.Context, types.NamespacedName{Namespace: scope.Cars.Namespace, Name: name}, obj)
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func setComponentCondition(cars *infrav1alpha1.Cars, conditionType string, ready bool, reason string, message string) {
+	status := metav1.ConditionFalse
+	if ready {
+		status = metav1.ConditionTrue
+	}
+	apimeta.SetStatusCondition(&cars.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: cars.Generation,
+	})
+}
+
+// summarizePhase reduces the conditions into a single phase. A rejected spec or a reconcile error fails the
+// instance, steps waiting on a child leave it pending
+func summarizePhase(cars *infrav1alpha1.Cars) infrav1alpha1.CarsPhase {
+	if !cars.DeletionTimestamp.IsZero() {
+		return infrav1alpha1.CarsPhaseTerminating
+	}
+	reconciled := apimeta.FindStatusCondition(cars.Status.Conditions, infrav1alpha1.ConditionReconciled)
+	if reconciled != nil && reconciled.Status == metav1.ConditionFalse && reconciled.Reason != infrav1alpha1.ReconciledReasonWaiting {
+		return infrav1alpha1.CarsPhaseFailed
+	}
+	for _, condition := range []string{
+		infrav1alpha1.ConditionStorageBound,
+		infrav1alpha1.ConditionDatabaseReady,
+		infrav1alpha1.ConditionAppAvailable,
+		infrav1alpha1.ConditionIngressReady,
+		infrav1alpha1.ConditionCertificateReady,
+	} {
+		if apimeta.IsStatusConditionFalse(cars.Status.Conditions, condition) {
+			return infrav1alpha1.CarsPhasePending
+		}
+	}
+	return infrav1alpha1.CarsPhaseRunning
+}
@@ -6,4 +6,64 @@ const MysqlImage = "mysql:8.0"
 const CarsPort = 7777
 const MysqlPort = 3306
 
-const DefaultServiceAccount = "cars-operator-node"
+// CarsConfigDir is where the cars configuration is mounted, CarsConfigFile is its key in the ConfigMap
+const CarsConfigDir = "/etc/cars"
+const CarsConfigFile = "config.yaml"
+
+// Users the containers run as by default. MysqlUser is the mysql user of the official mysql image
+const CarsUser = 65532
+const MysqlUser = 999
+
+// Labels selecting the pods of a component of an instance, and their component values
+const InstanceLabel = "app.kubernetes.io/instance"
+const ComponentLabel = "app.kubernetes.io/component"
+const ComponentCars = "cars"
+const ComponentMysql = "mysql"
+
+// Names of the mysql PVC and Deployment rendered by earlier operator versions, which took no instance name
+const LegacyMysqlPVCName = "mysql-data"
+const LegacyMysqlName = "mysql"
+
+// Secrets exposed to the containers when the spec lists no environment sources
+const CarsEnvironmentSecret = "cars-environment"
+const MysqlEnvironmentSecret = "mysql-environment"
+
+// DefaultTargetCPUUtilization is the CPU utilization the cars pods are scaled at when autoscaling sets no target
+const DefaultTargetCPUUtilization = 80
+
+// Event reasons emitted on the Cars resource
+const (
+	EventReasonCreated       = "Created"
+	EventReasonUpdated       = "Updated"
+	EventReasonFailed        = "ReconcileFailed"
+	EventReasonBackupStarted = "BackupStarted"
+	EventReasonBackupFailed  = "BackupFailed"
+	EventReasonDataRetained  = "DataRetained"
+	EventReasonDataDeleted   = "DataDeleted"
+	EventReasonDataAdopted   = "DataAdopted"
+	EventReasonFieldConflict = "FieldConflict"
+	EventReasonPruned        = "Pruned"
+	EventReasonInvalidSpec   = "InvalidSpec"
+)
+
+// Reconcile step names, reported in the Cars status
+const (
+	StepMysqlPVC        = "mysql-pvc"
+	StepImages          = "images"
+	StepMysqlDeployment = "mysql-deployment"
+	StepMysqlService    = "mysql-service"
+	StepCarsConfig      = "cars-config"
+	StepNetworkKeys     = "network-keys"
+	StepCarsDeployment  = "cars-deployment"
+	StepCarsService     = "cars-service"
+	StepIngress         = "ingress"
+	StepCarsAutoscaler  = "cars-autoscaler"
+	StepMysqlPDB        = "mysql-disruption-budget"
+	StepCarsPDB         = "cars-disruption-budget"
+	StepServiceAccount  = "service-account"
+	StepRole            = "role"
+	StepRoleBinding     = "role-binding"
+)
+
+// FieldManager is the server-side apply field manager owning the fields the operator renders
+const FieldManager = "cars-operator"
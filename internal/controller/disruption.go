@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+	"github.com/bitcoin-sv/cars-operator/internal/utils"
+	policyv1 "k8s.io/api/policy/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// ReconcileMysqlPDB is the mysql PodDisruptionBudget reconciler
+func (r *CarsReconciler) ReconcileMysqlPDB(scope *reconcileScope) (utils.StepResult, error) {
+	cars := scope.Cars
+	// Skip unless a database budget is configured, a budget rendered before is pruned
+	if !mysqlPDBEnabled(cars) {
+		return utils.StepSkipped, nil
+	}
+	return r.applyPDB(scope, renderMysqlPDB(cars))
+}
+
+// ReconcileCarsPDB is the cars PodDisruptionBudget reconciler
+func (r *CarsReconciler) ReconcileCarsPDB(scope *reconcileScope) (utils.StepResult, error) {
+	cars := scope.Cars
+	// Skip unless more than one cars pod runs, a budget rendered before is pruned
+	if !carsPDBEnabled(cars) {
+		return utils.StepSkipped, nil
+	}
+	return r.applyPDB(scope, renderCarsPDB(cars))
+}
+
+func (r *CarsReconciler) applyPDB(scope *reconcileScope, pdb *policyv1.PodDisruptionBudget) (utils.StepResult, error) {
+	cars := scope.Cars
+	op, err := r.apply(scope, pdb, func() error {
+		return controllerutil.SetControllerReference(cars, pdb, r.Scheme)
+	})
+	r.recordResult(cars, "PodDisruptionBudget", pdb.Name, op, err)
+	if err != nil {
+		return utils.StepFailed, err
+	}
+	return utils.StepCompleted, nil
+}
+
+// mysqlPDBEnabled returns whether the mysql pod is guarded by a PodDisruptionBudget. It is opt-in, as the budget of
+// a single pod either blocks every node drain or does not guard anything
+func mysqlPDBEnabled(cars *infrav1alpha1.Cars) bool {
+	database := cars.Spec.Disruption.Database
+	return !cars.Spec.Disruption.Disabled && (database.MinAvailable != nil || database.MaxUnavailable != nil)
+}
+
+// carsPDBEnabled returns whether the cars pods are guarded by a PodDisruptionBudget. A single cars pod is not,
+// as a budget cannot keep it available during a drain without blocking it
+func carsPDBEnabled(cars *infrav1alpha1.Cars) bool {
+	return !cars.Spec.Disruption.Disabled && carsMinReplicas(cars) > 1
+}
+
+// carsMinReplicas returns the number of cars pods that run at least
+func carsMinReplicas(cars *infrav1alpha1.Cars) int32 {
+	if cars.Spec.Autoscaling != nil {
+		if cars.Spec.Autoscaling.MinReplicas != nil {
+			return *cars.Spec.Autoscaling.MinReplicas
+		}
+		return 1
+	}
+	if cars.Spec.Replicas != nil {
+		return *cars.Spec.Replicas
+	}
+	return 1
+}
+
+// renderMysqlPDB renders the mysql PodDisruptionBudget, leaving the owner reference to the reconciler
+func renderMysqlPDB(cars *infrav1alpha1.Cars) *policyv1.PodDisruptionBudget {
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      mysqlName(cars),
+			Namespace: cars.Namespace,
+			Labels:    getAppLabels(cars),
+		},
+		Spec: pdbSpec(defaultMysqlDeploymentSpec(cars).Selector, cars.Spec.Disruption.Database, 1),
+	}
+}
+
+// renderCarsPDB renders the cars PodDisruptionBudget, leaving the owner reference to the reconciler
+func renderCarsPDB(cars *infrav1alpha1.Cars) *policyv1.PodDisruptionBudget {
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      carsName(cars),
+			Namespace: cars.Namespace,
+			Labels:    getAppLabels(cars),
+		},
+		Spec: pdbSpec(defaultCarsDeploymentSpec(cars).Selector, cars.Spec.Disruption.Cars, 1),
+	}
+}
+
+// pdbSpec selects the pods of a deployment, allowing maxUnavailable pods to be disrupted unless the spec sets a budget
+func pdbSpec(selector *metav1.LabelSelector, spec infrav1alpha1.PodDisruptionSpec, maxUnavailable int) policyv1.PodDisruptionBudgetSpec {
+	pdb := policyv1.PodDisruptionBudgetSpec{
+		Selector:       selector,
+		MaxUnavailable: ptr.To(intstr.FromInt(maxUnavailable)),
+	}
+	switch {
+	case spec.MinAvailable != nil:
+		pdb.MinAvailable = ptr.To(*spec.MinAvailable)
+		pdb.MaxUnavailable = nil
+	case spec.MaxUnavailable != nil:
+		pdb.MaxUnavailable = ptr.To(*spec.MaxUnavailable)
+	}
+	return pdb
+}
+
+// observeDisruption sets whether the PodDisruptionBudgets currently allow evicting a pod, so blocked node drains
+// can be traced back to the instance
+func (r *CarsReconciler) observeDisruption(scope *reconcileScope) error {
+	cars := scope.Cars
+	names := []string{}
+	if mysqlPDBEnabled(cars) {
+		names = append(names, mysqlName(cars))
+	}
+	if carsPDBEnabled(cars) {
+		names = append(names, carsName(cars))
+	}
+	if len(names) == 0 {
+		apimeta.RemoveStatusCondition(&cars.Status.Conditions, infrav1alpha1.ConditionDisruptionAllowed)
+		return nil
+	}
+	blocked := []string{}
+	for _, name := range names {
+		pdb := policyv1.PodDisruptionBudget{}
+		found, err := r.getChild(scope, name, &pdb)
+		if err != nil {
+			return err
+		}
+		if !found {
+			setComponentCondition(cars, infrav1alpha1.ConditionDisruptionAllowed, false, infrav1alpha1.ReasonNotFound,
+				fmt.Sprintf("PodDisruptionBudget %s not found", name))
+			return nil
+		}
+		if pdb.Status.DisruptionsAllowed < 1 {
+			blocked = append(blocked, fmt.Sprintf("%s (%d/%d pods healthy)", name, pdb.Status.CurrentHealthy, pdb.Status.ExpectedPods))
+		}
+	}
+	if len(blocked) > 0 {
+		setComponentCondition(cars, infrav1alpha1.ConditionDisruptionAllowed, false, infrav1alpha1.ReasonEvictionBlocked,
+			fmt.Sprintf("evictions are blocked by PodDisruptionBudget %s", strings.Join(blocked, ", ")))
+		return nil
+	}
+	setComponentCondition(cars, infrav1alpha1.ConditionDisruptionAllowed, true, infrav1alpha1.ReasonEvictionAllowed,
+		"every PodDisruptionBudget allows evictions")
+	return nil
+}
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// envFromOrDefault returns the configured environment sources, or the default ones when none are configured
+func envFromOrDefault(configured []corev1.EnvFromSource, defaults []corev1.EnvFromSource) []corev1.EnvFromSource {
+	if len(configured) == 0 {
+		return defaults
+	}
+	envFrom := make([]corev1.EnvFromSource, 0, len(configured))
+	for _, source := range configured {
+		envFrom = append(envFrom, *source.DeepCopy())
+	}
+	return envFrom
+}
+
+// mergeEnv merges the configured variables into the rendered ones. A configured variable replaces a rendered variable
+// of the same name in place, the others are appended in their configured order with the last of duplicate names winning,
+// so the result only depends on its inputs
+func mergeEnv(rendered []corev1.EnvVar, configured []corev1.EnvVar) []corev1.EnvVar {
+	env := make([]corev1.EnvVar, 0, len(rendered)+len(configured))
+	index := make(map[string]int, len(rendered)+len(configured))
+	for _, variable := range append(append([]corev1.EnvVar{}, rendered...), configured...) {
+		if i, ok := index[variable.Name]; ok {
+			env[i] = *variable.DeepCopy()
+			continue
+		}
+		index[variable.Name] = len(env)
+		env = append(env, *variable.DeepCopy())
+	}
+	return env
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+	"github.com/bitcoin-sv/cars-operator/internal/registry"
+	"github.com/bitcoin-sv/cars-operator/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Names the resolved images are recorded under in the status
+const (
+	carsImageName  = "cars"
+	mysqlImageName = "mysql"
+)
+
+// ReconcileImages resolves the cars and mysql image tags to digests and records them in the scope. The deployments
+// depend on this step, so they render with the resolved digests
+func (r *CarsReconciler) ReconcileImages(scope *reconcileScope) (utils.StepResult, error) {
+	cars := scope.Cars
+	// Skip if digests aren't resolved, the containers then run the tags
+	if !cars.Spec.ResolveImageDigests {
+		scope.setImages(nil)
+		return utils.StepSkipped, nil
+	}
+
+	pullSecrets := []corev1.Secret{}
+	for _, ref := range cars.Spec.ImagePullSecrets {
+		secret := corev1.Secret{}
+		found, err := r.getChild(scope, ref.Name, &secret)
+		if err != nil {
+			return utils.StepFailed, err
+		}
+		if found {
+			pullSecrets = append(pullSecrets, secret)
+		}
+	}
+
+	images := []infrav1alpha1.ImageStatus{
+		{Name: carsImageName, Image: carsImageTag(cars)},
+		{Name: mysqlImageName, Image: mysqlImageTag(cars)},
+	}
+	for i, image := range images {
+		digest, err := r.resolver().Resolve(scope.Context, image.Image, pullSecrets)
+		if err != nil {
+			return utils.StepFailed, fmt.Errorf("resolving %s image %s: %w", image.Name, image.Image, err)
+		}
+		images[i].Digest = digest
+	}
+	scope.setImages(images)
+	return utils.StepCompleted, nil
+}
+
+// withResolvedImages returns a copy of the instance carrying the images resolved during this reconcile, for rendering
+// the containers before the status writer records them
+func withResolvedImages(scope *reconcileScope) *infrav1alpha1.Cars {
+	cars := scope.Cars.DeepCopy()
+	if images, ok := scope.Images(); ok {
+		cars.Status.Images = images
+	}
+	return cars
+}
+
+func (r *CarsReconciler) resolver() registry.Resolver {
+	if r.Resolver != nil {
+		return r.Resolver
+	}
+	return &registry.HTTPResolver{}
+}
+
+// carsImageTag is the image of the cars container as configured
+func carsImageTag(cars *infrav1alpha1.Cars) string {
+	if cars.Spec.Image != "" {
+		return cars.Spec.Image
+	}
+	return DefaultImage
+}
+
+// mysqlImageTag is the image of the mysql container as configured
+func mysqlImageTag(cars *infrav1alpha1.Cars) string {
+	if cars.Spec.Database.Image != "" {
+		return cars.Spec.Database.Image
+	}
+	return MysqlImage
+}
+
+// carsImage is the image the cars container runs, pinned to its resolved digest
+func carsImage(cars *infrav1alpha1.Cars) string {
+	return pinnedImage(cars, carsImageName, carsImageTag(cars))
+}
+
+// mysqlImage is the image the mysql container and backup job run, pinned to its resolved digest
+func mysqlImage(cars *infrav1alpha1.Cars) string {
+	return pinnedImage(cars, mysqlImageName, mysqlImageTag(cars))
+}
+
+// pinnedImage appends the digest recorded for an image, as long as it was resolved from the same reference
+func pinnedImage(cars *infrav1alpha1.Cars, name string, image string) string {
+	if !cars.Spec.ResolveImageDigests || strings.Contains(image, "@") {
+		return image
+	}
+	for _, resolved := range cars.Status.Images {
+		if resolved.Name == name && resolved.Image == image && resolved.Digest != "" {
+			return image + "@" + resolved.Digest
+		}
+	}
+	return image
+}
+
+// imagePullPolicy is the pull policy of all containers, defaulting to Always
+func imagePullPolicy(cars *infrav1alpha1.Cars) corev1.PullPolicy {
+	if cars.Spec.ImagePullPolicy != "" {
+		return cars.Spec.ImagePullPolicy
+	}
+	return corev1.PullAlways
+}
+
+// imagePullSecrets are the pull secrets of all pods
+func imagePullSecrets(cars *infrav1alpha1.Cars) []corev1.LocalObjectReference {
+	if len(cars.Spec.ImagePullSecrets) == 0 {
+		return nil
+	}
+	return append([]corev1.LocalObjectReference{}, cars.Spec.ImagePullSecrets...)
+}
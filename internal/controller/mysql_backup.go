@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"fmt"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// backupMysql runs a final dump of the cars database into a PVC that is not owned by the Cars instance.
+// It returns true once the dump has completed
+func (r *CarsReconciler) backupMysql(scope *reconcileScope, dataPVC *corev1.PersistentVolumeClaim) (bool, error) {
+	cars := scope.Cars
+	backupName := types.NamespacedName{
+		Namespace: cars.Namespace,
+		Name:      mysqlBackupName(cars),
+	}
+
+	job := batchv1.Job{}
+	started, err := r.getChild(scope, backupName.Name, &job)
+	if err != nil {
+		return false, err
+	}
+	if !started {
+		// The dump connects to the mysql service, which a foreground deletion of the instance may have taken down
+		reason, err := r.mysqlUnavailable(scope)
+		if err != nil {
+			return false, err
+		}
+		if reason != "" {
+			r.Recorder.Eventf(cars, corev1.EventTypeWarning, EventReasonBackupFailed,
+				"Cannot back up the database before deleting PersistentVolumeClaim %s: %s. Restore it or change the retention policy to proceed",
+				dataPVC.Name, reason)
+			return false, nil
+		}
+	}
+
+	// The backup PVC deliberately has no owner reference so it survives the Cars instance
+	pvc := corev1.PersistentVolumeClaim{}
+	err = r.Get(scope.Context, backupName, &pvc)
+	if k8serrors.IsNotFound(err) {
+		pvc = corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      backupName.Name,
+				Namespace: backupName.Namespace,
+				Labels:    getAppLabels(cars),
+			},
+			Spec: *defaultMysqlBackupPVCSpec(dataPVC),
+		}
+		err = r.Create(scope.Context, &pvc)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !started {
+		job = batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      backupName.Name,
+				Namespace: backupName.Namespace,
+				Labels:    getAppLabels(cars),
+			},
+			Spec: *defaultMysqlBackupJobSpec(cars),
+		}
+		if err = controllerutil.SetControllerReference(cars, &job, r.Scheme); err != nil {
+			return false, err
+		}
+		if err = r.Create(scope.Context, &job); err != nil {
+			return false, err
+		}
+		r.Recorder.Eventf(cars, corev1.EventTypeNormal, EventReasonBackupStarted, "Started backup Job %s writing to PersistentVolumeClaim %s", job.Name, pvc.Name)
+		return false, nil
+	}
+
+	if job.Status.Succeeded > 0 {
+		return true, nil
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			// Keep the finalizer so the data is not lost; the retention policy can be changed to proceed
+			r.Recorder.Eventf(cars, corev1.EventTypeWarning, EventReasonBackupFailed, "Backup Job %s failed: %s", job.Name, condition.Message)
+			return false, fmt.Errorf("mysql backup job %s failed: %s", job.Name, condition.Message)
+		}
+	}
+	return false, nil
+}
+
+// mysqlUnavailable returns why the mysql deployment cannot serve a dump, or an empty string when it can
+func (r *CarsReconciler) mysqlUnavailable(scope *reconcileScope) (string, error) {
+	dep := appsv1.Deployment{}
+	found, err := r.getChild(scope, mysqlName(scope.Cars), &dep)
+	switch {
+	case err != nil:
+		return "", err
+	case !found:
+		return fmt.Sprintf("Deployment %s is gone", mysqlName(scope.Cars)), nil
+	case !dep.DeletionTimestamp.IsZero():
+		return fmt.Sprintf("Deployment %s is being deleted", dep.Name), nil
+	case dep.Status.AvailableReplicas == 0:
+		return fmt.Sprintf("Deployment %s has no available replica", dep.Name), nil
+	}
+	return "", nil
+}
+
+func defaultMysqlBackupPVCSpec(dataPVC *corev1.PersistentVolumeClaim) *corev1.PersistentVolumeClaimSpec {
+	spec := defaultPVCSpec()
+	// Size the backup like the data it holds
+	spec.StorageClassName = dataPVC.Spec.StorageClassName
+	spec.Resources = *dataPVC.Spec.Resources.DeepCopy()
+	return spec
+}
+
+func defaultMysqlBackupJobSpec(cars *infrav1alpha1.Cars) *batchv1.JobSpec {
+	// The dump needs the same credentials as the mysql container
+	envFrom := envFromOrDefault(cars.Spec.Database.EnvFrom, []corev1.EnvFromSource{
+		{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: MysqlEnvironmentSecret,
+				},
+			},
+		},
+	})
+	dump := fmt.Sprintf(
+		`MYSQL_PWD="$MYSQL_ROOT_PASSWORD" mysqldump -h %s -P %d -uroot --all-databases --single-transaction > /backup/%s-$(date +%%Y%%m%%d%%H%%M%%S).sql`,
+		mysqlName(cars), MysqlPort, cars.Name,
+	)
+	spec := &batchv1.JobSpec{
+		BackoffLimit: ptr.To(int32(3)),
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				CreationTimestamp: metav1.Time{},
+			},
+			Spec: corev1.PodSpec{
+				RestartPolicy:    corev1.RestartPolicyNever,
+				ImagePullSecrets: imagePullSecrets(cars),
+				Containers: []corev1.Container{
+					{
+						EnvFrom:         envFrom,
+						Env:             mergeEnv(nil, cars.Spec.Database.Env),
+						Image:           mysqlImage(cars),
+						ImagePullPolicy: imagePullPolicy(cars),
+						Name:            "mysql-backup",
+						Command: []string{
+							"sh",
+							"-c",
+							dump,
+						},
+						VolumeMounts: []corev1.VolumeMount{
+							{
+								MountPath: "/backup",
+								Name:      "mysql-backup",
+							},
+						},
+					},
+				},
+				Volumes: []corev1.Volume{
+					{
+						Name: "mysql-backup",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+								ClaimName: mysqlBackupName(cars),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	applySecurityContext(&spec.Template.Spec, cars.Spec.Database.PodSecurityContext, cars.Spec.Database.SecurityContext, MysqlUser)
+	mountWritableDirs(&spec.Template.Spec, []writableDir{{Name: "tmp", Path: "/tmp"}})
+	return spec
+}
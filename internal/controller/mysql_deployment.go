@@ -2,7 +2,7 @@ package controller
 
 import (
 	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
-	"github.com/go-logr/logr"
+	"github.com/bitcoin-sv/cars-operator/internal/utils"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -12,47 +12,54 @@ import (
 )
 
 // ReconcileMysqlDeployment is the cars db deployment reconciler
-func (r *CarsReconciler) ReconcileMysqlDeployment(log logr.Logger) (bool, error) {
-	cars := infrav1alpha1.Cars{}
-	if err := r.Get(r.Context, r.NamespacedName, &cars); err != nil {
-		return false, err
-	}
-	dep := appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "mysql",
-			Namespace: r.NamespacedName.Namespace,
-			Labels:    getAppLabels(),
-		},
-	}
-	_, err := controllerutil.CreateOrUpdate(r.Context, r.Client, &dep, func() error {
-		return r.updateMysqlDeployment(&dep, &cars)
+func (r *CarsReconciler) ReconcileMysqlDeployment(scope *reconcileScope) (utils.StepResult, error) {
+	cars := scope.Cars
+	dep := renderMysqlDeployment(withResolvedImages(scope))
+	op, err := r.apply(scope, dep, func() error {
+		return controllerutil.SetControllerReference(cars, dep, r.Scheme)
 	})
+	r.recordResult(cars, "Deployment", dep.Name, op, err)
 	if err != nil {
-		return false, err
+		return utils.StepFailed, err
 	}
-	return true, nil
+	return utils.StepCompleted, nil
 }
 
-func (r *CarsReconciler) updateMysqlDeployment(dep *appsv1.Deployment, cars *infrav1alpha1.Cars) error {
-	err := controllerutil.SetControllerReference(cars, dep, r.Scheme)
-	if err != nil {
-		return err
+// renderMysqlDeployment renders the mysql deployment, leaving the owner reference to the reconciler
+func renderMysqlDeployment(cars *infrav1alpha1.Cars) *appsv1.Deployment {
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      mysqlName(cars),
+			Namespace: cars.Namespace,
+			Labels:    getAppLabels(cars),
+		},
+		Spec: *defaultMysqlDeploymentSpec(cars),
+	}
+
+	container := &dep.Spec.Template.Spec.Containers[0]
+	container.EnvFrom = envFromOrDefault(cars.Spec.Database.EnvFrom, container.EnvFrom)
+	container.Env = mergeEnv(container.Env, cars.Spec.Database.Env)
+	applyScheduling(&dep.Spec.Template.Spec, cars.Spec.Database.Scheduling, nil)
+	applySecurityContext(&dep.Spec.Template.Spec, cars.Spec.Database.PodSecurityContext, cars.Spec.Database.SecurityContext, MysqlUser)
+	mountWritableDirs(&dep.Spec.Template.Spec, mysqlWritableDirs)
+	if cars.Spec.Database.Resources != nil {
+		container.Resources = *cars.Spec.Database.Resources.DeepCopy()
 	}
-	dep.Spec = *defaultMysqlDeploymentSpec()
 
-	return nil
+	// Overlaid last, like the cars pod template
+	_ = applyPodTemplate(&dep.Spec.Template, cars.Spec.Database.PodTemplate)
+	secureOverlaidContainers(&dep.Spec.Template.Spec, cars.Spec.Database.SecurityContext)
+
+	return dep
 }
 
-func defaultMysqlDeploymentSpec() *appsv1.DeploymentSpec {
-	labels := map[string]string{
-		"app":        "mysql",
-		"deployment": "mysql",
-	}
+func defaultMysqlDeploymentSpec(cars *infrav1alpha1.Cars) *appsv1.DeploymentSpec {
+	labels := selectorLabels(cars, ComponentMysql)
 	envFrom := []corev1.EnvFromSource{
 		{
 			SecretRef: &corev1.SecretEnvSource{
 				LocalObjectReference: corev1.LocalObjectReference{
-					Name: "mysql-environment",
+					Name: MysqlEnvironmentSecret,
 				},
 			},
 		},
@@ -67,14 +74,15 @@ func defaultMysqlDeploymentSpec() *appsv1.DeploymentSpec {
 				Labels:            labels,
 			},
 			Spec: corev1.PodSpec{
+				ImagePullSecrets: imagePullSecrets(cars),
 				Containers: []corev1.Container{
 					{
 						EnvFrom:         envFrom,
 						Env:             env,
-						Image:           MysqlImage,
-						ImagePullPolicy: corev1.PullAlways,
+						Image:           mysqlImage(cars),
+						ImagePullPolicy: imagePullPolicy(cars),
 						Name:            "mysql",
-						// Make sane defaults, and this should be configurable
+						// Sane defaults, replaced by spec.database.resources
 						Resources: corev1.ResourceRequirements{
 							Limits: corev1.ResourceList{
 								corev1.ResourceMemory: resource.MustParse("500Mi"),
@@ -115,7 +123,7 @@ func defaultMysqlDeploymentSpec() *appsv1.DeploymentSpec {
 						Name: "mysql-data",
 						VolumeSource: corev1.VolumeSource{
 							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-								ClaimName: "mysql-data",
+								ClaimName: mysqlPVCName(cars),
 							},
 						},
 					},
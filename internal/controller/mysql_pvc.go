@@ -2,38 +2,38 @@ package controller
 
 import (
 	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
-	"github.com/go-logr/logr"
+	"github.com/bitcoin-sv/cars-operator/internal/utils"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 // ReconcileMysqlPVC is the mysql PVC
-func (r *CarsReconciler) ReconcileMysqlPVC(log logr.Logger) (bool, error) {
-	cars := infrav1alpha1.Cars{}
-	if err := r.Get(r.Context, r.NamespacedName, &cars); err != nil {
-		return false, err
+func (r *CarsReconciler) ReconcileMysqlPVC(scope *reconcileScope) (utils.StepResult, error) {
+	cars := scope.Cars
+	retiring, err := r.adoptLegacyMysqlPVC(scope)
+	if err != nil {
+		return utils.StepFailed, err
 	}
-	pvc := corev1.PersistentVolumeClaim{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "mysql-data",
-			Namespace: r.NamespacedName.Namespace,
-			Labels:    getAppLabels(),
-		},
+	if retiring {
+		return utils.StepStopped, nil
 	}
+
 	// Check if PVC is already created so that we can copy the existing spec values
 	// This is how we properly support resizing
 	existingPvcNamespacedName := types.NamespacedName{
-		Namespace: pvc.Namespace,
-		Name:      pvc.Name,
+		Namespace: cars.Namespace,
+		Name:      mysqlPVCName(cars),
 	}
 	existingPVC := &corev1.PersistentVolumeClaim{}
-	err := r.Get(r.Context, existingPvcNamespacedName, existingPVC)
+	err = r.Get(scope.Context, existingPvcNamespacedName, existingPVC)
 	if err != nil && !k8serrors.IsNotFound(err) {
-		return false, err
+		return utils.StepFailed, err
 	}
 
 	// If in cluster PVC is not found; nil it out to not confuse the create or update section
@@ -41,21 +41,67 @@ func (r *CarsReconciler) ReconcileMysqlPVC(log logr.Logger) (bool, error) {
 		existingPVC = nil
 	}
 
-	_, err = controllerutil.CreateOrUpdate(r.Context, r.Client, &pvc, func() error {
-		return r.updatePVC(&pvc, existingPVC, &cars)
+	pvc := renderMysqlPVC(cars, existingPVC)
+	op, err := r.apply(scope, pvc, func() error {
+		return controllerutil.SetControllerReference(cars, pvc, r.Scheme)
 	})
 
 	// Ignore forbidden errors
 	if err != nil && !k8serrors.IsForbidden(err) {
+		r.recordResult(cars, "PersistentVolumeClaim", pvc.Name, op, err)
+		return utils.StepFailed, err
+	}
+	r.recordResult(cars, "PersistentVolumeClaim", pvc.Name, op, nil)
+	return utils.StepCompleted, nil
+}
+
+// adoptLegacyMysqlPVC keeps the data of an install of an earlier operator version, which named the PVC mysql-data for
+// every instance. A legacy PVC controlled by this instance is recorded in the status so the mysql deployment mounts it
+// instead of a new empty one. The legacy mysql deployment is deleted first, as two mysql servers must not share the
+// data, and true is returned until it is gone. The legacy services and cars deployment are pruned as usual
+func (r *CarsReconciler) adoptLegacyMysqlPVC(scope *reconcileScope) (bool, error) {
+	cars := scope.Cars
+	if cars.Status.MysqlPVC == "" {
+		legacy := corev1.PersistentVolumeClaim{}
+		found, err := r.getChild(scope, LegacyMysqlPVCName, &legacy)
+		if err != nil || !found || !metav1.IsControlledBy(&legacy, cars) {
+			// A legacy PVC of another instance or of nobody is left alone
+			return false, err
+		}
+		cars.Status.MysqlPVC = legacy.Name
+		r.Recorder.Eventf(cars, corev1.EventTypeWarning, EventReasonDataAdopted,
+			"Adopted PersistentVolumeClaim %s of an earlier operator version, the database is now served as %s:%d, update MYSQL_DATABASE_URL accordingly",
+			legacy.Name, mysqlName(cars), MysqlPort)
+	}
+	if cars.Status.MysqlPVC != LegacyMysqlPVCName {
+		return false, nil
+	}
+
+	dep := appsv1.Deployment{}
+	found, err := r.getChild(scope, LegacyMysqlName, &dep)
+	if err != nil || !found || !metav1.IsControlledBy(&dep, cars) {
 		return false, err
 	}
+	if dep.DeletionTimestamp.IsZero() {
+		scope.Log.Info("deleting legacy mysql deployment before mounting its data", "deployment", dep.Name)
+		// Foreground so the deployment lingers until its pods are gone
+		err = r.Delete(scope.Context, &dep, client.Preconditions{UID: &dep.UID}, client.PropagationPolicy(metav1.DeletePropagationForeground))
+		if err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		r.Recorder.Eventf(cars, corev1.EventTypeNormal, EventReasonPruned, "Pruned Deployment %s", dep.Name)
+	}
 	return true, nil
 }
 
-func (r *CarsReconciler) updatePVC(pvc *corev1.PersistentVolumeClaim, inClusterPVC *corev1.PersistentVolumeClaim, cars *infrav1alpha1.Cars) error {
-	err := controllerutil.SetControllerReference(cars, pvc, r.Scheme)
-	if err != nil {
-		return err
+// renderMysqlPVC renders the mysql data PVC on top of the in-cluster one, if any, leaving the owner reference to the reconciler
+func renderMysqlPVC(cars *infrav1alpha1.Cars, inClusterPVC *corev1.PersistentVolumeClaim) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      mysqlPVCName(cars),
+			Namespace: cars.Namespace,
+			Labels:    getAppLabels(cars),
+		},
 	}
 	if inClusterPVC == nil {
 		pvc.Spec = *defaultPVCSpec()
@@ -74,7 +120,7 @@ func (r *CarsReconciler) updatePVC(pvc *corev1.PersistentVolumeClaim, inClusterP
 	if cars.Spec.StorageVolume != "" {
 		pvc.Spec.VolumeName = cars.Spec.StorageVolume
 	}
-	return nil
+	return pvc
 }
 
 func defaultPVCSpec() *corev1.PersistentVolumeClaimSpec {
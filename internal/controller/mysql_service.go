@@ -2,7 +2,7 @@ package controller
 
 import (
 	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
-	"github.com/go-logr/logr"
+	"github.com/bitcoin-sv/cars-operator/internal/utils"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -10,40 +10,33 @@ import (
 )
 
 // ReconcileMysqlService is the mysql service reconciler
-func (r *CarsReconciler) ReconcileMysqlService(log logr.Logger) (bool, error) {
-	cars := infrav1alpha1.Cars{}
-	if err := r.Get(r.Context, r.NamespacedName, &cars); err != nil {
-		return false, err
-	}
-	svc := corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "mysql",
-			Namespace: r.NamespacedName.Namespace,
-			Labels:    getAppLabels(),
-		},
-	}
-	_, err := controllerutil.CreateOrUpdate(r.Context, r.Client, &svc, func() error {
-		return r.updateMysqlService(&svc, &cars)
+func (r *CarsReconciler) ReconcileMysqlService(scope *reconcileScope) (utils.StepResult, error) {
+	cars := scope.Cars
+	svc := renderMysqlService(cars)
+	op, err := r.apply(scope, svc, func() error {
+		return controllerutil.SetControllerReference(cars, svc, r.Scheme)
 	})
+	r.recordResult(cars, "Service", svc.Name, op, err)
 	if err != nil {
-		return false, err
+		return utils.StepFailed, err
 	}
-	return true, nil
+	return utils.StepCompleted, nil
 }
 
-func (r *CarsReconciler) updateMysqlService(svc *corev1.Service, cars *infrav1alpha1.Cars) error {
-	err := controllerutil.SetControllerReference(cars, svc, r.Scheme)
-	if err != nil {
-		return err
+// renderMysqlService renders the mysql service, leaving the owner reference to the reconciler
+func renderMysqlService(cars *infrav1alpha1.Cars) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      mysqlName(cars),
+			Namespace: cars.Namespace,
+			Labels:    getAppLabels(cars),
+		},
+		Spec: *defaultMysqlServiceSpec(cars),
 	}
-	svc.Spec = *defaultMysqlServiceSpec()
-	return nil
 }
 
-func defaultMysqlServiceSpec() *corev1.ServiceSpec {
-	labels := map[string]string{
-		"app": "mysql",
-	}
+func defaultMysqlServiceSpec(cars *infrav1alpha1.Cars) *corev1.ServiceSpec {
+	labels := selectorLabels(cars, ComponentMysql)
 	ipFamily := corev1.IPFamilyPolicySingleStack
 	return &corev1.ServiceSpec{
 		Selector:       labels,
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+)
+
+// carsName is the name of the cars Deployment, Service and Ingress. The suffix keeps it apart from the children of an
+// instance named <name>-mysql or the like
+func carsName(cars *infrav1alpha1.Cars) string {
+	return fmt.Sprintf("%s-cars", cars.Name)
+}
+
+// carsConfigName is the name of the ConfigMap holding the cars application configuration
+func carsConfigName(cars *infrav1alpha1.Cars) string {
+	return fmt.Sprintf("%s-config", cars.Name)
+}
+
+// mysqlName is the name of the mysql Deployment and Service
+func mysqlName(cars *infrav1alpha1.Cars) string {
+	return fmt.Sprintf("%s-mysql", cars.Name)
+}
+
+// selectorLabels select the pods of a component of the Cars instance
+func selectorLabels(cars *infrav1alpha1.Cars, component string) map[string]string {
+	return map[string]string{
+		InstanceLabel:  cars.Name,
+		ComponentLabel: component,
+	}
+}
+
+// mysqlPVCName is the name of the mysql data PVC, the adopted one of an earlier operator version if any
+func mysqlPVCName(cars *infrav1alpha1.Cars) string {
+	if cars.Status.MysqlPVC != "" {
+		return cars.Status.MysqlPVC
+	}
+	return fmt.Sprintf("%s-mysql-data", cars.Name)
+}
+
+// carsTLSSecretName is the name of the secret holding the ingress certificate
+func carsTLSSecretName(cars *infrav1alpha1.Cars) string {
+	if cars.Spec.Ingress.TLS.SecretName != "" {
+		return cars.Spec.Ingress.TLS.SecretName
+	}
+	return fmt.Sprintf("%s-tls", cars.Name)
+}
+
+// mysqlBackupName is the name of the final backup Job and of the PVC it writes to. The Job labels its pods with its
+// name, so it must fit a label value
+func mysqlBackupName(cars *infrav1alpha1.Cars) string {
+	return boundedName(cars.Name, "-mysql-backup")
+}
+
+// boundedName appends the suffix to the instance name, truncating a name too long for a label value and keeping it
+// unique with a hash of the full name
+func boundedName(name string, suffix string) string {
+	if len(name)+len(suffix) <= validation.LabelValueMaxLength {
+		return name + suffix
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:8]
+	prefix := strings.TrimRight(name[:validation.LabelValueMaxLength-len(suffix)-len(hash)-1], "-.")
+	return fmt.Sprintf("%s-%s%s", prefix, hash, suffix)
+}
+
+// carsHosts are the public host names of the cars API
+func carsHosts(cars *infrav1alpha1.Cars) []string {
+	if len(cars.Spec.Ingress.Hosts) > 0 {
+		return append([]string{}, cars.Spec.Ingress.Hosts...)
+	}
+	return []string{fmt.Sprintf("%s.%s", cars.Name, cars.Spec.Domain)}
+}
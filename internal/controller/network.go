@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+	"github.com/bitcoin-sv/cars-operator/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+// networkKey maps a key of the environment secret to the variable it is injected as
+type networkKey struct {
+	Variable string
+	Key      string
+}
+
+// networkKeys are the secret keys each network needs
+var networkKeys = map[infrav1alpha1.Network][]networkKey{
+	infrav1alpha1.NetworkMainnet: {
+		{Variable: "PRIVATE_KEY", Key: "MAINNET_PRIVATE_KEY"},
+		{Variable: "TAAL_API_KEY", Key: "TAAL_API_KEY_MAIN"},
+	},
+	infrav1alpha1.NetworkTestnet: {
+		{Variable: "PRIVATE_KEY", Key: "TESTNET_PRIVATE_KEY"},
+		{Variable: "TAAL_API_KEY", Key: "TAAL_API_KEY_TEST"},
+	},
+}
+
+// sharedKeys are the keys of the environment secret every network needs
+var sharedKeys = []string{"MYSQL_DATABASE_URL"}
+
+// ReconcileNetworkKeys checks that the environment secret holds the keys of the configured network, so the cars
+// pods do not start without them
+func (r *CarsReconciler) ReconcileNetworkKeys(scope *reconcileScope) (utils.StepResult, error) {
+	cars := scope.Cars
+	// Skip if network isn't set, the cars app then reads the keys itself
+	if cars.Spec.Network == "" {
+		return utils.StepSkipped, nil
+	}
+	secret := corev1.Secret{}
+	found, err := r.getChild(scope, carsKeysSecretName(cars), &secret)
+	if err != nil {
+		return utils.StepFailed, err
+	}
+	if !found {
+		return utils.StepFailed, fmt.Errorf("secret %s holding the %s keys not found", carsKeysSecretName(cars), cars.Spec.Network)
+	}
+	missing := []string{}
+	for _, key := range networkKeys[cars.Spec.Network] {
+		if len(secret.Data[key.Key]) == 0 {
+			missing = append(missing, key.Key)
+		}
+	}
+	if len(missing) > 0 {
+		return utils.StepFailed, fmt.Errorf("secret %s is missing the %s keys %s", secret.Name, cars.Spec.Network, strings.Join(missing, ", "))
+	}
+	return utils.StepCompleted, nil
+}
+
+// networkEnv injects the keys of the configured network, under their own and network independent names, along with
+// the network and the shared keys. The environment secret is not exposed as a whole then, see networkEnvFrom
+func networkEnv(cars *infrav1alpha1.Cars) []corev1.EnvVar {
+	if cars.Spec.Network == "" {
+		return nil
+	}
+	env := []corev1.EnvVar{
+		{
+			Name:  "NETWORK",
+			Value: string(cars.Spec.Network),
+		},
+	}
+	for _, key := range networkKeys[cars.Spec.Network] {
+		env = append(env, secretKeyEnv(cars, key.Key, key.Key, false), secretKeyEnv(cars, key.Variable, key.Key, false))
+	}
+	for _, key := range sharedKeys {
+		env = append(env, secretKeyEnv(cars, key, key, true))
+	}
+	return env
+}
+
+// networkEnvFrom drops the environment secret from the sources of the cars container when a network is configured,
+// so the keys of the other network do not reach the pods
+func networkEnvFrom(cars *infrav1alpha1.Cars, envFrom []corev1.EnvFromSource) []corev1.EnvFromSource {
+	if cars.Spec.Network == "" {
+		return envFrom
+	}
+	sources := make([]corev1.EnvFromSource, 0, len(envFrom))
+	for _, source := range envFrom {
+		if source.SecretRef != nil && source.SecretRef.Name == carsKeysSecretName(cars) {
+			continue
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+func secretKeyEnv(cars *infrav1alpha1.Cars, name string, key string, optional bool) corev1.EnvVar {
+	variable := corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: carsKeysSecretName(cars),
+				},
+				Key: key,
+			},
+		},
+	}
+	if optional {
+		variable.ValueFrom.SecretKeyRef.Optional = ptr.To(true)
+	}
+	return variable
+}
+
+// carsKeysSecretName is the first Secret exposed to the cars container, which holds the network keys
+func carsKeysSecretName(cars *infrav1alpha1.Cars) string {
+	for _, source := range cars.Spec.EnvFrom {
+		if source.SecretRef != nil {
+			return source.SecretRef.Name
+		}
+	}
+	return CarsEnvironmentSecret
+}
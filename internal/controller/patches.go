@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+)
+
+// ApplyPatches applies the patches targeting a child to it in order. A patch must not rename or move the child,
+// as the reconcile steps and pruning find children by name
+func ApplyPatches(obj client.Object, kind string, patches []infrav1alpha1.CarsPatch) error {
+	name, namespace := obj.GetName(), obj.GetNamespace()
+	for i, patch := range patches {
+		if patch.Target.Kind != kind || (patch.Target.Name != "" && patch.Target.Name != name) {
+			continue
+		}
+		if err := applyPatch(obj, patch); err != nil {
+			return fmt.Errorf("spec.patches[%d]: %w", i, err)
+		}
+		if obj.GetName() != name || obj.GetNamespace() != namespace {
+			return fmt.Errorf("spec.patches[%d]: must not change the name or namespace", i)
+		}
+	}
+	return nil
+}
+
+func applyPatch(obj client.Object, patch infrav1alpha1.CarsPatch) error {
+	body, err := yaml.YAMLToJSON([]byte(patch.Patch))
+	if err != nil {
+		return err
+	}
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	var patched []byte
+	switch patch.Type {
+	case infrav1alpha1.PatchTypeJSON6902:
+		operations, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			return err
+		}
+		if patched, err = operations.Apply(original); err != nil {
+			return err
+		}
+	default:
+		if patched, err = strategicpatch.StrategicMergePatch(original, body, obj); err != nil {
+			return err
+		}
+	}
+	// Decode into a zero value, decoding into obj would keep map entries the patch removed
+	result := reflect.New(reflect.TypeOf(obj).Elem())
+	if err := json.Unmarshal(patched, result.Interface()); err != nil {
+		return err
+	}
+	reflect.ValueOf(obj).Elem().Set(result.Elem())
+	return nil
+}
+
+// setPatchesCondition reports the patches that failed to apply during the last reconcile
+func setPatchesCondition(scope *reconcileScope) {
+	cars := scope.Cars
+	if len(cars.Spec.Patches) == 0 {
+		apimeta.RemoveStatusCondition(&cars.Status.Conditions, infrav1alpha1.ConditionPatchesApplied)
+		return
+	}
+	patchErrors := scope.PatchErrors()
+	if len(patchErrors) == 0 {
+		setComponentCondition(cars, infrav1alpha1.ConditionPatchesApplied, true, infrav1alpha1.ReasonPatched,
+			"All patches applied")
+		return
+	}
+	setComponentCondition(cars, infrav1alpha1.ConditionPatchesApplied, false, infrav1alpha1.ReasonPatchFailed,
+		strings.Join(patchErrors, "; "))
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// applyPodTemplate merges an overlay into a pod template as a strategic merge patch, like kubectl patch does.
+// Fields unknown to a pod template are rejected so typos do not go unnoticed
+func applyPodTemplate(template *corev1.PodTemplateSpec, overlay *runtime.RawExtension) error {
+	if overlay == nil || len(overlay.Raw) == 0 {
+		return nil
+	}
+	original, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+	merged, err := strategicpatch.StrategicMergePatch(original, overlay.Raw, corev1.PodTemplateSpec{})
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	result := corev1.PodTemplateSpec{}
+	if err := decoder.Decode(&result); err != nil {
+		return err
+	}
+	*template = result
+	return nil
+}
+
+// validatePodTemplate applies an overlay to a deployment rendered without it, and rejects overlays that fail to
+// apply, relabel the pods away from the deployment selector or remove a container the operator renders
+func validatePodTemplate(dep *appsv1.Deployment, overlay *runtime.RawExtension, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if overlay == nil {
+		return errs
+	}
+	template := dep.Spec.Template.DeepCopy()
+	if err := applyPodTemplate(template, overlay); err != nil {
+		return append(errs, field.Invalid(path, string(overlay.Raw), err.Error()))
+	}
+	keys := make([]string, 0, len(dep.Spec.Selector.MatchLabels))
+	for key := range dep.Spec.Selector.MatchLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if template.Labels[key] != dep.Spec.Selector.MatchLabels[key] {
+			errs = append(errs, field.Forbidden(path.Child("metadata", "labels").Key(key), "must not change a selector label"))
+		}
+	}
+	for _, container := range dep.Spec.Template.Spec.Containers {
+		if !hasContainer(template.Spec.Containers, container.Name) {
+			errs = append(errs, field.Forbidden(path.Child("spec", "containers"),
+				fmt.Sprintf("must keep the %s container", container.Name)))
+		}
+	}
+	return errs
+}
+
+func hasContainer(containers []corev1.Container, name string) bool {
+	for _, container := range containers {
+		if container.Name == name {
+			return true
+		}
+	}
+	return false
+}
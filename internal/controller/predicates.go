@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// childSpecChanged passes deletions of owned children and updates that change anything besides their status,
+// so manual edits and deletions are corrected. Creations are ignored as the children are created by the reconciler
+func childSpecChanged() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			before, after := splitStatus(e.ObjectOld), splitStatus(e.ObjectNew)
+			return !equality.Semantic.DeepEqual(before.rest, after.rest)
+		},
+	}
+}
+
+// childStatusChanged passes updates of owned children that only change their status
+func childStatusChanged() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			before, after := splitStatus(e.ObjectOld), splitStatus(e.ObjectNew)
+			return equality.Semantic.DeepEqual(before.rest, after.rest) && !equality.Semantic.DeepEqual(before.status, after.status)
+		},
+	}
+}
+
+type splitObject struct {
+	status interface{}
+	rest   map[string]interface{}
+}
+
+// splitStatus separates the status of an object from the rest of it. Of the metadata only the fields a user or
+// the reconciler sets are kept, the ones maintained by the API server change on every write
+func splitStatus(obj client.Object) splitObject {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		// Treat unconvertible objects as changed
+		return splitObject{rest: map[string]interface{}{"object": obj}}
+	}
+	status := content["status"]
+	delete(content, "status")
+	content["metadata"] = map[string]interface{}{
+		"labels":          obj.GetLabels(),
+		"annotations":     obj.GetAnnotations(),
+		"ownerReferences": obj.GetOwnerReferences(),
+		"deletion":        obj.GetDeletionTimestamp(),
+	}
+	return splitObject{status: status, rest: content}
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// defaultCarsStartupProbe gives the cars app five minutes to open its listen port before liveness takes over
+func defaultCarsStartupProbe(cars *infrav1alpha1.Cars) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler:     carsProbeHandler(cars),
+		PeriodSeconds:    5,
+		FailureThreshold: 60,
+	}
+}
+
+func defaultCarsLivenessProbe(cars *infrav1alpha1.Cars) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler:     carsProbeHandler(cars),
+		PeriodSeconds:    10,
+		TimeoutSeconds:   5,
+		FailureThreshold: 3,
+	}
+}
+
+func defaultCarsReadinessProbe(cars *infrav1alpha1.Cars) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler:     carsProbeHandler(cars),
+		PeriodSeconds:    5,
+		TimeoutSeconds:   3,
+		FailureThreshold: 3,
+	}
+}
+
+// carsProbeHandler checks the cars listen port over TCP, as the API has no dedicated health endpoint
+func carsProbeHandler(cars *infrav1alpha1.Cars) corev1.ProbeHandler {
+	return corev1.ProbeHandler{
+		TCPSocket: &corev1.TCPSocketAction{
+			Port: intstr.FromInt32(carsListenPort(cars)),
+		},
+	}
+}
+
+// overrideProbe returns the configured probe, keeping the handler of the default probe when it configures none
+func overrideProbe(defaults *corev1.Probe, configured *corev1.Probe) *corev1.Probe {
+	if configured == nil {
+		return defaults
+	}
+	probe := configured.DeepCopy()
+	handler := probe.ProbeHandler
+	if handler.Exec == nil && handler.HTTPGet == nil && handler.TCPSocket == nil && handler.GRPC == nil {
+		probe.ProbeHandler = defaults.ProbeHandler
+	}
+	return probe
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+)
+
+// prunableTypes lists the kinds of children that are deleted once they are no longer rendered.
+// PersistentVolumeClaims are left to the storage retention policy
+func prunableTypes() []client.ObjectList {
+	return []client.ObjectList{
+		&appsv1.DeploymentList{},
+		&corev1.ServiceList{},
+		&corev1.ConfigMapList{},
+		&networkingv1.IngressList{},
+		&autoscalingv2.HorizontalPodAutoscalerList{},
+		&policyv1.PodDisruptionBudgetList{},
+		&corev1.ServiceAccountList{},
+		&rbacv1.RoleList{},
+		&rbacv1.RoleBindingList{},
+	}
+}
+
+// pruneChildren takes the inventory of children labeled as part of the Cars instance and controlled by it, and deletes
+// the ones the reconcile steps did not render. With PruneDryRun they are only logged
+func (r *CarsReconciler) pruneChildren(scope *reconcileScope) error {
+	cars := scope.Cars
+	for _, list := range prunableTypes() {
+		if err := r.List(scope.Context, list, client.InNamespace(cars.Namespace), client.HasLabels{infrav1alpha1.CarsLabel}); err != nil {
+			return err
+		}
+		items, err := apimeta.ExtractList(list)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			obj, ok := item.(client.Object)
+			if !ok || !metav1.IsControlledBy(obj, cars) || !obj.GetDeletionTimestamp().IsZero() {
+				continue
+			}
+			gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+			if err != nil {
+				return err
+			}
+			if scope.Rendered(gvk.Kind, obj.GetName()) {
+				continue
+			}
+			if r.PruneDryRun {
+				scope.Log.Info("would prune child that is no longer desired (dry run)", "kind", gvk.Kind, "name", obj.GetName())
+				continue
+			}
+			scope.Log.Info("pruning child that is no longer desired", "kind", gvk.Kind, "name", obj.GetName())
+			if err := client.IgnoreNotFound(r.Delete(scope.Context, obj, client.PropagationPolicy(metav1.DeletePropagationBackground))); err != nil {
+				return err
+			}
+			r.Recorder.Eventf(cars, corev1.EventTypeNormal, EventReasonPruned, "Pruned %s %s", gvk.Kind, obj.GetName())
+		}
+	}
+	return nil
+}
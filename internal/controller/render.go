@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+)
+
+// Render returns the children a Cars instance is rendered into, in reconcile order, without talking to a cluster.
+// Owner references are left out and the mysql PVC is rendered as it would be created
+func Render(cars *infrav1alpha1.Cars) []client.Object {
+	objects := []client.Object{
+		renderMysqlPVC(cars, nil),
+		renderMysqlDeployment(cars),
+		renderMysqlService(cars),
+		renderCarsConfigMap(cars),
+	}
+	if serviceAccountEnabled(cars) {
+		objects = append(objects, renderCarsServiceAccount(cars), renderCarsRole(cars), renderCarsRoleBinding(cars))
+	}
+	objects = append(objects,
+		renderCarsDeployment(cars),
+		renderCarsService(cars),
+	)
+	if ingressEnabled(cars) {
+		objects = append(objects, renderCarsIngress(cars))
+	}
+	if cars.Spec.Autoscaling != nil {
+		objects = append(objects, renderCarsAutoscaler(cars))
+	}
+	if mysqlPDBEnabled(cars) {
+		objects = append(objects, renderMysqlPDB(cars))
+	}
+	if carsPDBEnabled(cars) {
+		objects = append(objects, renderCarsPDB(cars))
+	}
+	return objects
+}
@@ -0,0 +1,762 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/ptr"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+)
+
+func renderedNames(cars *infrav1alpha1.Cars) []string {
+	names := []string{}
+	for _, obj := range Render(cars) {
+		names = append(names, obj.GetName())
+	}
+	return names
+}
+
+func TestRenderListsChildrenInReconcileOrder(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+	}
+	want := "render-mysql-data render-mysql render-mysql render-config render-cars render-cars render-cars render-cars render-cars"
+	if got := strings.Join(renderedNames(cars), " "); got != want {
+		t.Fatalf("rendered %s, want %s", got, want)
+	}
+
+	cars.Spec.Domain = "example.com"
+	want = "render-mysql-data render-mysql render-mysql render-config render-cars render-cars render-cars render-cars render-cars render-cars"
+	if got := strings.Join(renderedNames(cars), " "); got != want {
+		t.Fatalf("rendered %s with an ingress, want %s", got, want)
+	}
+	for _, obj := range Render(cars) {
+		if obj.GetNamespace() != "default" || obj.GetLabels()[infrav1alpha1.CarsLabel] != "render" {
+			t.Errorf("%s is not labeled as part of the instance: %v", obj.GetName(), obj.GetLabels())
+		}
+		if len(obj.GetOwnerReferences()) != 0 {
+			t.Errorf("%s is rendered with owner references", obj.GetName())
+		}
+	}
+}
+
+func TestRenderKeepsInstancesApart(t *testing.T) {
+	foo := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+	}
+	fooMysql := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo-mysql", Namespace: "default"},
+	}
+	names := map[string]bool{}
+	for _, obj := range Render(foo) {
+		names[fmt.Sprintf("%T/%s", obj, obj.GetName())] = true
+	}
+	for _, obj := range Render(fooMysql) {
+		if name := fmt.Sprintf("%T/%s", obj, obj.GetName()); names[name] {
+			t.Errorf("%s is rendered for both foo and foo-mysql", name)
+		}
+	}
+
+	selected := labels.SelectorFromSet(renderMysqlService(foo).Spec.Selector)
+	if selected.Matches(labels.Set(renderCarsDeployment(fooMysql).Spec.Template.Labels)) {
+		t.Errorf("the mysql service of foo selects the cars pods of foo-mysql")
+	}
+	if !selected.Matches(labels.Set(renderMysqlDeployment(foo).Spec.Template.Labels)) {
+		t.Errorf("the mysql service of foo does not select its mysql pods")
+	}
+}
+
+func TestMysqlBackupNameFitsLabelValue(t *testing.T) {
+	short := &infrav1alpha1.Cars{ObjectMeta: metav1.ObjectMeta{Name: "backup"}}
+	if got := mysqlBackupName(short); got != "backup-mysql-backup" {
+		t.Errorf("backup of a short name is %s, want backup-mysql-backup", got)
+	}
+
+	long := &infrav1alpha1.Cars{ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("a", 40) + ".cars-" + strings.Repeat("b", 20)}}
+	other := long.DeepCopy()
+	other.Name += "c"
+	name := mysqlBackupName(long)
+	if errs := validation.IsValidLabelValue(name); len(errs) > 0 {
+		t.Errorf("backup name %s is not a label value: %v", name, errs)
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		t.Errorf("backup name %s is not a job name: %v", name, errs)
+	}
+	if name == mysqlBackupName(other) {
+		t.Errorf("instances sharing a long prefix both back up to %s", name)
+	}
+}
+
+func TestRenderCarsDeploymentUsesImage(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+	}
+	if image := renderCarsDeployment(cars).Spec.Template.Spec.Containers[0].Image; image != DefaultImage {
+		t.Errorf("image = %s, want %s", image, DefaultImage)
+	}
+	cars.Spec.Image = "docker.io/galtbv/cars:v1"
+	if image := renderCarsDeployment(cars).Spec.Template.Spec.Containers[0].Image; image != cars.Spec.Image {
+		t.Errorf("image = %s, want %s", image, cars.Spec.Image)
+	}
+}
+
+func TestRenderMysqlPVCKeepsInClusterSpec(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+		Spec: infrav1alpha1.CarsSpec{
+			StorageClass: "fast",
+		},
+	}
+	pvc := renderMysqlPVC(cars, nil)
+	if *pvc.Spec.StorageClassName != "fast" || !pvc.Spec.Resources.Requests.Storage().Equal(resource.MustParse("5Gi")) {
+		t.Errorf("unexpected default spec: %+v", pvc.Spec)
+	}
+
+	inCluster := pvc.DeepCopy()
+	inCluster.Spec.VolumeName = "pv-1"
+	inCluster.Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("10Gi")
+	pvc = renderMysqlPVC(cars, inCluster)
+	if pvc.Spec.VolumeName != "pv-1" || !pvc.Spec.Resources.Requests.Storage().Equal(resource.MustParse("10Gi")) {
+		t.Errorf("in-cluster spec was not kept: %+v", pvc.Spec)
+	}
+}
+
+func TestRenderMysqlDeploymentMountsDataPVC(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+	}
+	dep := renderMysqlDeployment(cars)
+	claim := dep.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim
+	if claim == nil || claim.ClaimName != mysqlPVCName(cars) {
+		t.Errorf("mysql deployment does not mount %s: %+v", mysqlPVCName(cars), dep.Spec.Template.Spec.Volumes)
+	}
+
+	// The PVC of an earlier operator version is mounted once adopted
+	cars.Status.MysqlPVC = LegacyMysqlPVCName
+	dep = renderMysqlDeployment(cars)
+	claim = dep.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim
+	if claim == nil || claim.ClaimName != LegacyMysqlPVCName {
+		t.Errorf("mysql deployment does not mount the adopted PVC: %+v", dep.Spec.Template.Spec.Volumes)
+	}
+}
+
+func TestRenderAutoscalingLeavesReplicasAlone(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+		Spec: infrav1alpha1.CarsSpec{
+			Replicas: ptr.To(int32(3)),
+		},
+	}
+	if replicas := renderCarsDeployment(cars).Spec.Replicas; replicas == nil || *replicas != 3 {
+		t.Errorf("replicas = %v, want 3", replicas)
+	}
+
+	cars.Spec.Autoscaling = &infrav1alpha1.CarsAutoscalingSpec{MaxReplicas: 5}
+	if replicas := renderCarsDeployment(cars).Spec.Replicas; replicas != nil {
+		t.Errorf("replicas = %d, want them left to the autoscaler", *replicas)
+	}
+	hpa := renderCarsAutoscaler(cars)
+	if *hpa.Spec.MinReplicas != 1 || hpa.Spec.MaxReplicas != 5 || hpa.Spec.ScaleTargetRef.Name != "render-cars" {
+		t.Errorf("unexpected autoscaler spec: %+v", hpa.Spec)
+	}
+	if len(hpa.Spec.Metrics) != 1 || hpa.Spec.Metrics[0].Resource.Name != corev1.ResourceCPU ||
+		*hpa.Spec.Metrics[0].Resource.Target.AverageUtilization != DefaultTargetCPUUtilization {
+		t.Errorf("autoscaler does not default to the CPU target: %+v", hpa.Spec.Metrics)
+	}
+}
+
+func TestRenderReplacesDefaultResources(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+	}
+	cars.Spec.Database.Resources = &corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+	}
+	resources := renderMysqlDeployment(cars).Spec.Template.Spec.Containers[0].Resources
+	if !resources.Limits.Memory().Equal(resource.MustParse("2Gi")) || len(resources.Requests) != 0 {
+		t.Errorf("mysql resources = %+v, want only the 2Gi memory limit", resources)
+	}
+	if resources := renderCarsDeployment(cars).Spec.Template.Spec.Containers[0].Resources; !resources.Limits.Memory().Equal(resource.MustParse("500Mi")) {
+		t.Errorf("cars resources = %+v, want the defaults", resources)
+	}
+}
+
+func TestRenderMergesEnvironment(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+	}
+	envFrom := renderCarsDeployment(cars).Spec.Template.Spec.Containers[0].EnvFrom
+	if len(envFrom) != 1 || envFrom[0].SecretRef.Name != CarsEnvironmentSecret {
+		t.Errorf("envFrom = %+v, want the %s secret", envFrom, CarsEnvironmentSecret)
+	}
+
+	cars.Spec.EnvFrom = []corev1.EnvFromSource{
+		{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "render-keys"}}},
+		{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "render-settings"}}},
+	}
+	cars.Spec.Database.Env = []corev1.EnvVar{
+		{Name: "B", Value: "1"},
+		{Name: "A", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "render-mysql"},
+			Key:                  "password",
+		}}},
+		{Name: "B", Value: "2"},
+	}
+	envFrom = renderCarsDeployment(cars).Spec.Template.Spec.Containers[0].EnvFrom
+	if len(envFrom) != 2 || envFrom[0].SecretRef.Name != "render-keys" || envFrom[1].ConfigMapRef.Name != "render-settings" {
+		t.Errorf("envFrom = %+v, want the configured sources in order", envFrom)
+	}
+	env := renderMysqlDeployment(cars).Spec.Template.Spec.Containers[0].Env
+	if len(env) != 2 || env[0].Name != "B" || env[0].Value != "2" || env[1].Name != "A" || env[1].ValueFrom == nil {
+		t.Errorf("env = %+v, want B=2 followed by A from the secret", env)
+	}
+	if job := defaultMysqlBackupJobSpec(cars); len(job.Template.Spec.Containers[0].Env) != 2 {
+		t.Errorf("backup job env = %+v, want the database env", job.Template.Spec.Containers[0].Env)
+	}
+}
+
+func TestRenderConfigRollsThePods(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+	}
+	cars.Spec.Config = infrav1alpha1.CarsConfigSpec{
+		LogLevel:   "debug",
+		ListenPort: ptr.To(int32(8080)),
+		Broadcasters: []infrav1alpha1.CarsBroadcasterSpec{
+			{Name: "arc", URL: "https://arc.example.com"},
+		},
+		Extra: map[string]string{"logLevel": "error", "feeModel": "standard"},
+	}
+	want := `broadcasters:
+- name: arc
+  url: https://arc.example.com
+feeModel: standard
+listenPort: 8080
+logLevel: debug
+`
+	if got := renderCarsConfigMap(cars).Data[CarsConfigFile]; got != want {
+		t.Errorf("config = %q, want %q", got, want)
+	}
+
+	dep := renderCarsDeployment(cars)
+	hash := dep.Spec.Template.Annotations[infrav1alpha1.ConfigHashAnnotation]
+	if hash == "" || dep.Spec.Template.Spec.Containers[0].Ports[0].ContainerPort != 8080 {
+		t.Fatalf("deployment does not follow the config: %+v", dep.Spec.Template)
+	}
+	if renderCarsService(cars).Spec.Ports[0].TargetPort.IntVal != 8080 {
+		t.Errorf("service does not target the listen port")
+	}
+	cars.Spec.Config.LogLevel = "info"
+	if renderCarsDeployment(cars).Spec.Template.Annotations[infrav1alpha1.ConfigHashAnnotation] == hash {
+		t.Errorf("config hash did not change with the config")
+	}
+}
+
+func TestRenderProbesOnListenPort(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+	}
+	container := renderCarsDeployment(cars).Spec.Template.Spec.Containers[0]
+	for _, probe := range []*corev1.Probe{container.StartupProbe, container.LivenessProbe, container.ReadinessProbe} {
+		if probe == nil || probe.TCPSocket == nil || probe.TCPSocket.Port.IntVal != CarsPort {
+			t.Fatalf("probe %+v does not check port %d", probe, CarsPort)
+		}
+	}
+
+	cars.Spec.Probes.Readiness = &corev1.Probe{PeriodSeconds: 20}
+	cars.Spec.Probes.Liveness = &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/health", Port: intstr.FromInt32(CarsPort)}},
+	}
+	container = renderCarsDeployment(cars).Spec.Template.Spec.Containers[0]
+	if readiness := container.ReadinessProbe; readiness.PeriodSeconds != 20 || readiness.TCPSocket == nil {
+		t.Errorf("readiness = %+v, want the default handler with a 20s period", readiness)
+	}
+	if liveness := container.LivenessProbe; liveness.HTTPGet == nil || liveness.TCPSocket != nil {
+		t.Errorf("liveness = %+v, want the configured HTTP check", liveness)
+	}
+}
+
+func TestRenderNetworkSelectsKeys(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+		Spec: infrav1alpha1.CarsSpec{
+			Network: infrav1alpha1.NetworkTestnet,
+			EnvFrom: []corev1.EnvFromSource{
+				{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "render-testnet"}}},
+			},
+		},
+	}
+	env := map[string]corev1.EnvVar{}
+	for _, variable := range renderCarsDeployment(cars).Spec.Template.Spec.Containers[0].Env {
+		env[variable.Name] = variable
+	}
+	if env["NETWORK"].Value != "testnet" {
+		t.Errorf("NETWORK = %q, want testnet", env["NETWORK"].Value)
+	}
+	for variable, key := range map[string]string{"PRIVATE_KEY": "TESTNET_PRIVATE_KEY", "TAAL_API_KEY": "TAAL_API_KEY_TEST"} {
+		ref := env[variable].ValueFrom
+		if ref == nil || ref.SecretKeyRef.Name != "render-testnet" || ref.SecretKeyRef.Key != key {
+			t.Errorf("%s = %+v, want %s of render-testnet", variable, env[variable], key)
+		}
+	}
+	if ref := env["MYSQL_DATABASE_URL"].ValueFrom; ref == nil || ref.SecretKeyRef.Name != "render-testnet" {
+		t.Errorf("MYSQL_DATABASE_URL = %+v, want it from render-testnet", env["MYSQL_DATABASE_URL"])
+	}
+	for _, obj := range Render(cars) {
+		if obj.GetLabels()[infrav1alpha1.NetworkLabel] != "testnet" {
+			t.Errorf("%s is not labeled with the network", obj.GetName())
+		}
+	}
+}
+
+func TestRenderNetworkLeavesOtherKeysOut(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+		Spec: infrav1alpha1.CarsSpec{
+			Network: infrav1alpha1.NetworkMainnet,
+		},
+	}
+	container := renderCarsDeployment(cars).Spec.Template.Spec.Containers[0]
+	for _, source := range container.EnvFrom {
+		if source.SecretRef != nil && source.SecretRef.Name == CarsEnvironmentSecret {
+			t.Errorf("%s is exposed as a whole: %+v", CarsEnvironmentSecret, container.EnvFrom)
+		}
+	}
+	env := map[string]bool{}
+	for _, variable := range container.Env {
+		env[variable.Name] = true
+		if ref := variable.ValueFrom; ref != nil && ref.SecretKeyRef != nil && strings.Contains(ref.SecretKeyRef.Key, "TEST") {
+			t.Errorf("%s reads the testnet key %s", variable.Name, ref.SecretKeyRef.Key)
+		}
+	}
+	for _, name := range []string{"TESTNET_PRIVATE_KEY", "TAAL_API_KEY_TEST"} {
+		if env[name] {
+			t.Errorf("testnet key %s is injected into a mainnet pod", name)
+		}
+	}
+	for _, name := range []string{"MAINNET_PRIVATE_KEY", "TAAL_API_KEY_MAIN", "PRIVATE_KEY", "TAAL_API_KEY", "MYSQL_DATABASE_URL"} {
+		if !env[name] {
+			t.Errorf("%s is not injected", name)
+		}
+	}
+
+	// Without a network the secret is exposed as is
+	cars.Spec.Network = ""
+	container = renderCarsDeployment(cars).Spec.Template.Spec.Containers[0]
+	if len(container.EnvFrom) != 1 || container.EnvFrom[0].SecretRef.Name != CarsEnvironmentSecret {
+		t.Errorf("envFrom = %+v, want %s", container.EnvFrom, CarsEnvironmentSecret)
+	}
+}
+
+func TestRenderImagePolicy(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+		Spec: infrav1alpha1.CarsSpec{
+			Image:            "registry.example.com/cars:v1",
+			ImagePullPolicy:  corev1.PullIfNotPresent,
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "render-pull"}},
+			Database: infrav1alpha1.CarsDatabaseSpec{
+				Image: "registry.example.com/mysql:8.4",
+			},
+		},
+	}
+	for _, pod := range []corev1.PodSpec{
+		renderCarsDeployment(cars).Spec.Template.Spec,
+		renderMysqlDeployment(cars).Spec.Template.Spec,
+		defaultMysqlBackupJobSpec(cars).Template.Spec,
+	} {
+		if pod.Containers[0].ImagePullPolicy != corev1.PullIfNotPresent || len(pod.ImagePullSecrets) != 1 || pod.ImagePullSecrets[0].Name != "render-pull" {
+			t.Errorf("container %s does not follow the image policy", pod.Containers[0].Name)
+		}
+	}
+	if image := renderMysqlDeployment(cars).Spec.Template.Spec.Containers[0].Image; image != "registry.example.com/mysql:8.4" {
+		t.Errorf("mysql image = %s, want the override", image)
+	}
+
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	cars.Spec.ResolveImageDigests = true
+	cars.Status.Images = []infrav1alpha1.ImageStatus{
+		{Name: carsImageName, Image: "registry.example.com/cars:v1", Digest: digest},
+		{Name: mysqlImageName, Image: "registry.example.com/mysql:8.0", Digest: digest},
+	}
+	if image := renderCarsDeployment(cars).Spec.Template.Spec.Containers[0].Image; image != "registry.example.com/cars:v1@"+digest {
+		t.Errorf("cars image = %s, want it pinned to the resolved digest", image)
+	}
+	if image := renderMysqlDeployment(cars).Spec.Template.Spec.Containers[0].Image; image != "registry.example.com/mysql:8.4" {
+		t.Errorf("mysql image = %s, want the digest of another tag ignored", image)
+	}
+}
+
+func TestRenderResolvedImagesBeforeStatus(t *testing.T) {
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	scope := &reconcileScope{Cars: &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+		Spec: infrav1alpha1.CarsSpec{
+			Image:               "registry.example.com/cars:v2",
+			ResolveImageDigests: true,
+		},
+		Status: infrav1alpha1.CarsStatus{
+			Images: []infrav1alpha1.ImageStatus{{Name: carsImageName, Image: "registry.example.com/cars:v1", Digest: digest}},
+		},
+	}}
+	scope.setImages([]infrav1alpha1.ImageStatus{{Name: carsImageName, Image: "registry.example.com/cars:v2", Digest: digest}})
+
+	if image := renderCarsDeployment(withResolvedImages(scope)).Spec.Template.Spec.Containers[0].Image; image != "registry.example.com/cars:v2@"+digest {
+		t.Errorf("cars image = %s, want it pinned to the digest resolved during the reconcile", image)
+	}
+	if image := scope.Cars.Status.Images[0].Image; image != "registry.example.com/cars:v1" {
+		t.Errorf("status image = %s, want it left to the status writer", image)
+	}
+}
+
+func TestRenderScheduling(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+	}
+	pod := renderCarsDeployment(cars).Spec.Template.Spec
+	if pod.Affinity == nil || pod.Affinity.PodAntiAffinity == nil ||
+		pod.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm.TopologyKey != corev1.LabelHostname {
+		t.Errorf("cars pods do not prefer separate nodes: %+v", pod.Affinity)
+	}
+
+	cars.Spec.Database.Scheduling = infrav1alpha1.CarsSchedulingSpec{
+		NodeSelector:      map[string]string{"pool": "storage"},
+		Tolerations:       []corev1.Toleration{{Key: "storage", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}},
+		PriorityClassName: "database",
+	}
+	pod = renderMysqlDeployment(cars).Spec.Template.Spec
+	if pod.NodeSelector["pool"] != "storage" || len(pod.Tolerations) != 1 || pod.PriorityClassName != "database" || pod.Affinity != nil {
+		t.Errorf("mysql pod does not follow the database scheduling: %+v", pod)
+	}
+	if pod := renderCarsDeployment(cars).Spec.Template.Spec; pod.NodeSelector != nil || pod.PriorityClassName != "" {
+		t.Errorf("cars pod picked up the database scheduling: %+v", pod)
+	}
+
+	cars.Spec.Scheduling.Affinity = &corev1.Affinity{}
+	if pod := renderCarsDeployment(cars).Spec.Template.Spec; pod.Affinity.PodAntiAffinity != nil {
+		t.Errorf("configured affinity did not replace the default: %+v", pod.Affinity)
+	}
+}
+
+func TestRenderSecurityContext(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+	}
+	for _, pod := range []corev1.PodSpec{
+		renderCarsDeployment(cars).Spec.Template.Spec,
+		renderMysqlDeployment(cars).Spec.Template.Spec,
+	} {
+		if pod.SecurityContext == nil || !*pod.SecurityContext.RunAsNonRoot ||
+			pod.SecurityContext.SeccompProfile.Type != corev1.SeccompProfileTypeRuntimeDefault {
+			t.Errorf("pod security context is not restricted: %+v", pod.SecurityContext)
+		}
+		container := pod.Containers[0]
+		if sc := container.SecurityContext; sc == nil || *sc.AllowPrivilegeEscalation || !*sc.ReadOnlyRootFilesystem ||
+			len(sc.Capabilities.Drop) != 1 || sc.Capabilities.Drop[0] != "ALL" {
+			t.Errorf("%s container security context is not restricted: %+v", container.Name, sc)
+		}
+		if !hasMount(container, "/tmp") {
+			t.Errorf("%s container has no writable /tmp", container.Name)
+		}
+	}
+	if container := renderMysqlDeployment(cars).Spec.Template.Spec.Containers[0]; !hasMount(container, "/var/run/mysqld") {
+		t.Errorf("mysql container cannot write its socket")
+	}
+
+	cars.Spec.Database.SecurityContext = &corev1.SecurityContext{ReadOnlyRootFilesystem: ptr.To(false)}
+	if sc := renderMysqlDeployment(cars).Spec.Template.Spec.Containers[0].SecurityContext; *sc.ReadOnlyRootFilesystem || sc.Capabilities != nil {
+		t.Errorf("configured security context did not replace the default: %+v", sc)
+	}
+	if sc := renderCarsDeployment(cars).Spec.Template.Spec.Containers[0].SecurityContext; !*sc.ReadOnlyRootFilesystem {
+		t.Errorf("cars container picked up the database security context: %+v", sc)
+	}
+}
+
+func hasMount(container corev1.Container, path string) bool {
+	for _, mount := range container.VolumeMounts {
+		if mount.MountPath == path {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRenderDisruptionBudgets(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+	}
+	if carsPDBEnabled(cars) {
+		t.Errorf("a single cars pod is guarded by a disruption budget")
+	}
+	if mysqlPDBEnabled(cars) {
+		t.Errorf("the mysql pod is guarded by a disruption budget that was not configured")
+	}
+
+	// The strict budget is opt-in
+	cars.Spec.Disruption.Database.MaxUnavailable = ptr.To(intstr.FromInt(0))
+	if !mysqlPDBEnabled(cars) {
+		t.Fatalf("the configured mysql budget is not rendered")
+	}
+	mysql := renderMysqlPDB(cars)
+	if mysql.Spec.MaxUnavailable.IntValue() != 0 || mysql.Spec.MinAvailable != nil {
+		t.Errorf("mysql budget = %+v, want maxUnavailable 0", mysql.Spec)
+	}
+	if selector := defaultMysqlDeploymentSpec(cars).Selector; !reflect.DeepEqual(mysql.Spec.Selector, selector) {
+		t.Errorf("mysql budget selects %v, want the mysql pods %v", mysql.Spec.Selector, selector)
+	}
+
+	cars.Spec.Replicas = ptr.To(int32(3))
+	cars.Spec.Disruption.Cars.MinAvailable = ptr.To(intstr.FromString("50%"))
+	if !carsPDBEnabled(cars) {
+		t.Fatalf("three cars pods are not guarded by a disruption budget")
+	}
+	if spec := renderCarsPDB(cars).Spec; spec.MinAvailable.String() != "50%" || spec.MaxUnavailable != nil {
+		t.Errorf("cars budget = %+v, want minAvailable 50%%", spec)
+	}
+
+	cars.Spec.Autoscaling = &infrav1alpha1.CarsAutoscalingSpec{MaxReplicas: 5}
+	if carsPDBEnabled(cars) {
+		t.Errorf("cars pods scaling down to one are guarded by a disruption budget")
+	}
+
+	cars.Spec.Disruption.Disabled = true
+	for _, obj := range Render(cars) {
+		if _, ok := obj.(*policyv1.PodDisruptionBudget); ok {
+			t.Errorf("rendered budget %s with disruption budgets disabled", obj.GetName())
+		}
+	}
+}
+
+func TestRenderServiceAccount(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+	}
+	if name := renderCarsDeployment(cars).Spec.Template.Spec.ServiceAccountName; name != "render-cars" {
+		t.Errorf("cars pods run as %s, want the rendered account", name)
+	}
+	binding := renderCarsRoleBinding(cars)
+	if binding.RoleRef.Name != renderCarsRole(cars).Name || binding.Subjects[0].Name != renderCarsServiceAccount(cars).Name ||
+		binding.Subjects[0].Namespace != "default" {
+		t.Errorf("binding does not grant the role to the account: %+v", binding)
+	}
+
+	// An instance named default must not take over the default account of the namespace
+	named := &infrav1alpha1.Cars{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default"}}
+	if name := renderCarsServiceAccount(named).Name; name == "default" {
+		t.Errorf("instance named default renders the default account")
+	}
+
+	cars.Spec.ServiceAccountName = "existing"
+	if name := renderCarsDeployment(cars).Spec.Template.Spec.ServiceAccountName; name != "existing" {
+		t.Errorf("cars pods run as %s, want the referenced account", name)
+	}
+	for _, obj := range Render(cars) {
+		switch obj.(type) {
+		case *corev1.ServiceAccount, *rbacv1.Role, *rbacv1.RoleBinding:
+			t.Errorf("rendered %T %s for a referenced account", obj, obj.GetName())
+		}
+	}
+}
+
+func TestRenderPodTemplateOverlay(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+	}
+	cars.Spec.PodTemplate = &runtime.RawExtension{Raw: []byte(`{
+		"metadata": {"annotations": {"example.com/scrape": "true"}},
+		"spec": {
+			"initContainers": [{"name": "wait", "image": "busybox"}],
+			"containers": [
+				{"name": "cars", "volumeMounts": [{"name": "extra", "mountPath": "/extra"}]},
+				{"name": "log-forwarder", "image": "fluent-bit"}
+			],
+			"volumes": [{"name": "extra", "emptyDir": {}}]
+		}
+	}`)}
+	template := renderCarsDeployment(cars).Spec.Template
+	if template.Annotations["example.com/scrape"] != "true" || template.Annotations[infrav1alpha1.ConfigHashAnnotation] == "" {
+		t.Errorf("annotations = %v, want the overlay merged with the config hash", template.Annotations)
+	}
+	if len(template.Spec.InitContainers) != 1 || len(template.Spec.Containers) != 2 || template.Spec.Containers[0].Name != "cars" {
+		t.Fatalf("containers = %+v, want the sidecar added after the cars container", template.Spec.Containers)
+	}
+	container := template.Spec.Containers[0]
+	if container.Image != DefaultImage || !hasMount(container, "/extra") || !hasMount(container, CarsConfigDir) {
+		t.Errorf("cars container = %+v, want the extra mount merged into the rendered one", container)
+	}
+	if len(template.Spec.Volumes) != len(renderCarsDeployment(&infrav1alpha1.Cars{}).Spec.Template.Spec.Volumes)+1 {
+		t.Errorf("volumes = %+v, want the extra volume added", template.Spec.Volumes)
+	}
+	for _, added := range []corev1.Container{template.Spec.InitContainers[0], template.Spec.Containers[1]} {
+		if sc := added.SecurityContext; sc == nil || *sc.AllowPrivilegeEscalation || len(sc.Capabilities.Drop) != 1 {
+			t.Errorf("%s container security context is not restricted: %+v", added.Name, sc)
+		}
+	}
+
+	cars.Spec.Database.PodTemplate = &runtime.RawExtension{Raw: []byte(`{"spec": {"containers": [
+		{"name": "exporter", "image": "mysqld-exporter", "securityContext": {"readOnlyRootFilesystem": false}}
+	]}}`)}
+	for _, container := range renderMysqlDeployment(cars).Spec.Template.Spec.Containers {
+		if sc := container.SecurityContext; container.Name == "exporter" && (*sc.ReadOnlyRootFilesystem || sc.Capabilities != nil) {
+			t.Errorf("exporter security context = %+v, want the one the overlay sets", sc)
+		}
+	}
+}
+
+func TestApplyPatches(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+	}
+	cars.Spec.Patches = []infrav1alpha1.CarsPatch{
+		{
+			Target: infrav1alpha1.PatchTarget{Kind: "Service", Name: "render-cars"},
+			Type:   infrav1alpha1.PatchTypeStrategic,
+			Patch:  "metadata:\n  annotations:\n    example.com/internal: \"true\"\n",
+		},
+		{
+			Target: infrav1alpha1.PatchTarget{Kind: "PersistentVolumeClaim"},
+			Type:   infrav1alpha1.PatchTypeJSON6902,
+			Patch:  `[{"op": "add", "path": "/metadata/labels/backup", "value": "daily"}]`,
+		},
+	}
+	svc := renderCarsService(cars)
+	if err := ApplyPatches(svc, "Service", cars.Spec.Patches); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if svc.Annotations["example.com/internal"] != "true" || svc.Spec.Ports[0].Port != int32(CarsPort) {
+		t.Errorf("service = %+v, want the annotation added to the rendered service", svc)
+	}
+	mysqlSvc := renderMysqlService(cars)
+	if err := ApplyPatches(mysqlSvc, "Service", cars.Spec.Patches); err != nil || len(mysqlSvc.Annotations) != 0 {
+		t.Errorf("patched the mysql service by another name: %v, %v", mysqlSvc.Annotations, err)
+	}
+	pvc := renderMysqlPVC(cars, nil)
+	if err := ApplyPatches(pvc, "PersistentVolumeClaim", cars.Spec.Patches); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pvc.Labels["backup"] != "daily" || pvc.Labels[infrav1alpha1.CarsLabel] != "render" {
+		t.Errorf("pvc labels = %v, want the label added", pvc.Labels)
+	}
+
+	// The pods roll when a patch changes the config they mount
+	hash := renderCarsDeployment(cars).Spec.Template.Annotations[infrav1alpha1.ConfigHashAnnotation]
+	cars.Spec.Patches = append(cars.Spec.Patches, infrav1alpha1.CarsPatch{
+		Target: infrav1alpha1.PatchTarget{Kind: "ConfigMap"},
+		Type:   infrav1alpha1.PatchTypeStrategic,
+		Patch:  "data:\n  extra.yaml: \"enabled: true\"\n",
+	})
+	if renderCarsDeployment(cars).Spec.Template.Annotations[infrav1alpha1.ConfigHashAnnotation] == hash {
+		t.Errorf("config hash did not change with a patch of the config")
+	}
+
+	for want, patch := range map[string]infrav1alpha1.CarsPatch{
+		"spec.patches[0]": {
+			Target: infrav1alpha1.PatchTarget{Kind: "Service"},
+			Type:   infrav1alpha1.PatchTypeJSON6902,
+			Patch:  `[{"op": "remove", "path": "/metadata/annotations/missing"}]`,
+		},
+		"must not change the name": {
+			Target: infrav1alpha1.PatchTarget{Kind: "Service"},
+			Patch:  `{"metadata": {"name": "renamed"}}`,
+		},
+	} {
+		err := ApplyPatches(renderCarsService(cars), "Service", []infrav1alpha1.CarsPatch{patch})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %s to fail with %s, got %v", patch.Patch, want, err)
+		}
+	}
+}
+
+func TestRenderServiceExposure(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+	}
+	if svc := renderCarsService(cars); svc.Spec.ClusterIP != corev1.ClusterIPNone || svc.Spec.Type != "" || svc.Annotations != nil {
+		t.Errorf("service = %+v, want the headless default", svc)
+	}
+
+	cars.Spec.Service = infrav1alpha1.CarsServiceSpec{
+		Type:                     corev1.ServiceTypeLoadBalancer,
+		Annotations:              map[string]string{"service.beta.kubernetes.io/aws-load-balancer-type": "nlb"},
+		IPFamilyPolicy:           ptr.To(corev1.IPFamilyPolicyPreferDualStack),
+		LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+		ExternalTrafficPolicy:    corev1.ServiceExternalTrafficPolicyLocal,
+	}
+	svc := renderCarsService(cars)
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer || svc.Spec.ClusterIP != "" {
+		t.Errorf("service type = %s with cluster IP %q, want an allocated load balancer", svc.Spec.Type, svc.Spec.ClusterIP)
+	}
+	if *svc.Spec.IPFamilyPolicy != corev1.IPFamilyPolicyPreferDualStack || svc.Spec.IPFamilies != nil {
+		t.Errorf("ip families = %v %v, want dual stack picked by the cluster", *svc.Spec.IPFamilyPolicy, svc.Spec.IPFamilies)
+	}
+	if svc.Annotations["service.beta.kubernetes.io/aws-load-balancer-type"] != "nlb" ||
+		svc.Spec.LoadBalancerSourceRanges[0] != "10.0.0.0/8" || svc.Spec.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyLocal {
+		t.Errorf("service = %+v, want the load balancer settings", svc)
+	}
+	svc.Annotations["changed"] = "true"
+	if _, ok := cars.Spec.Service.Annotations["changed"]; ok {
+		t.Errorf("rendered annotations alias the spec")
+	}
+}
+
+func TestRenderIngress(t *testing.T) {
+	cars := &infrav1alpha1.Cars{
+		ObjectMeta: metav1.ObjectMeta{Name: "render", Namespace: "default"},
+	}
+	cars.Spec.Domain = "example.com"
+	ingress := renderCarsIngress(cars)
+	if *ingress.Spec.IngressClassName != "nginx" || ingress.Spec.Rules[0].Host != "render.example.com" ||
+		ingress.Spec.TLS != nil || ingress.Annotations != nil {
+		t.Errorf("ingress = %+v, want the domain shorthand over plain HTTP without an issuer", ingress)
+	}
+	cars.Spec.ClusterIssuer = "letsencrypt"
+	if ingress := renderCarsIngress(cars); len(ingress.Spec.TLS) != 1 || ingress.Spec.TLS[0].SecretName != "render-tls" {
+		t.Errorf("tls = %+v, want the certificate the issuer writes", ingress.Spec.TLS)
+	}
+
+	cars.Spec.Domain = ""
+	cars.Spec.ClusterIssuer = "letsencrypt"
+	cars.Spec.Ingress = infrav1alpha1.CarsIngressSpec{
+		ClassName:   ptr.To("traefik"),
+		Hosts:       []string{"cars.example.com", "api.example.com"},
+		Paths:       []infrav1alpha1.CarsIngressPath{{Path: "/api"}},
+		Annotations: map[string]string{"traefik.ingress.kubernetes.io/router.entrypoints": "websecure"},
+		TLS:         infrav1alpha1.CarsIngressTLSSpec{SecretName: "cars-cert"},
+	}
+	if !ingressEnabled(cars) {
+		t.Fatalf("ingress with hosts and no domain is not rendered")
+	}
+	ingress = renderCarsIngress(cars)
+	if *ingress.Spec.IngressClassName != "traefik" || len(ingress.Spec.Rules) != 2 || ingress.Spec.Rules[1].Host != "api.example.com" {
+		t.Errorf("ingress = %+v, want a traefik rule per host", ingress.Spec)
+	}
+	path := ingress.Spec.Rules[1].HTTP.Paths[0]
+	if path.Path != "/api" || *path.PathType != networkingv1.PathTypePrefix || path.Backend.Service.Name != "render-cars" {
+		t.Errorf("path = %+v, want a prefix to the cars service", path)
+	}
+	if tls := ingress.Spec.TLS[0]; tls.SecretName != "cars-cert" || len(tls.Hosts) != 2 {
+		t.Errorf("tls = %+v, want both hosts in the configured secret", tls)
+	}
+	if ingress.Annotations["cert-manager.io/cluster-issuer"] != "letsencrypt" ||
+		ingress.Annotations["traefik.ingress.kubernetes.io/router.entrypoints"] != "websecure" {
+		t.Errorf("annotations = %v, want the issuer and the configured annotations", ingress.Annotations)
+	}
+
+	cars.Spec.Ingress.TLS.Disabled = true
+	if ingress := renderCarsIngress(cars); ingress.Spec.TLS != nil || ingress.Annotations["cert-manager.io/cluster-issuer"] != "" {
+		t.Errorf("ingress = %+v, want no certificate requested with TLS disabled", ingress)
+	}
+}
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// applyScheduling sets the scheduling fields of a pod, keeping the default affinity when none is configured
+func applyScheduling(pod *corev1.PodSpec, scheduling infrav1alpha1.CarsSchedulingSpec, defaultAffinity *corev1.Affinity) {
+	scheduling = *scheduling.DeepCopy()
+	pod.NodeSelector = scheduling.NodeSelector
+	pod.Tolerations = scheduling.Tolerations
+	pod.TopologySpreadConstraints = scheduling.TopologySpreadConstraints
+	pod.PriorityClassName = scheduling.PriorityClassName
+	pod.Affinity = defaultAffinity
+	if scheduling.Affinity != nil {
+		pod.Affinity = scheduling.Affinity
+	}
+}
+
+// defaultCarsAffinity prefers spreading the cars pods across nodes, so a node failure does not take down every replica
+func defaultCarsAffinity(cars *infrav1alpha1.Cars) *corev1.Affinity {
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+				{
+					Weight: 100,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: metav1.SetAsLabelSelector(selectorLabels(cars, ComponentCars)),
+						TopologyKey:   corev1.LabelHostname,
+					},
+				},
+			},
+		},
+	}
+}
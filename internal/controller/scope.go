@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"context"
+	"sync"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+)
+
+// reconcileScope carries the state of a single reconcile request through the reconcile steps.
+// Keeping it off CarsReconciler lets several Cars resources be reconciled concurrently
+type reconcileScope struct {
+	Context context.Context
+	Log     logr.Logger
+	Cars    *infrav1alpha1.Cars
+
+	// mu guards the fields below, which steps running in parallel write to
+	mu          sync.Mutex
+	conflicts   []string
+	patchErrors []string
+	rendered    map[string]bool
+	images      []infrav1alpha1.ImageStatus
+	imagesSet   bool
+}
+
+// addConflict records a server-side apply conflict with another field manager
+func (s *reconcileScope) addConflict(conflict string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conflicts = append(s.conflicts, conflict)
+}
+
+// Conflicts returns the server-side apply conflicts recorded so far
+func (s *reconcileScope) Conflicts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.conflicts...)
+}
+
+// addPatchError records a patch of spec.patches that failed to apply
+func (s *reconcileScope) addPatchError(patchError string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.patchErrors = append(s.patchErrors, patchError)
+}
+
+// PatchErrors returns the patch failures recorded so far
+func (s *reconcileScope) PatchErrors() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.patchErrors...)
+}
+
+// addRendered records a child the reconcile steps rendered, which keeps it from being pruned
+func (s *reconcileScope) addRendered(kind string, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rendered == nil {
+		s.rendered = make(map[string]bool)
+	}
+	s.rendered[kind+"/"+name] = true
+}
+
+// Rendered returns whether a child was rendered by the reconcile steps
+func (s *reconcileScope) Rendered(kind string, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rendered[kind+"/"+name]
+}
+
+// setImages records the images resolved by the images step, the status writer copies them into the status
+func (s *reconcileScope) setImages(images []infrav1alpha1.ImageStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.images = images
+	s.imagesSet = true
+}
+
+// Images returns the images resolved so far and whether the images step recorded any
+func (s *reconcileScope) Images() ([]infrav1alpha1.ImageStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]infrav1alpha1.ImageStatus(nil), s.images...), s.imagesSet
+}
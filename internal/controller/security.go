@@ -0,0 +1,90 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+// writableDir is an emptyDir mounted over a path a container writes to, as its root filesystem is read-only
+type writableDir struct {
+	Name string
+	Path string
+}
+
+var carsWritableDirs = []writableDir{
+	{Name: "tmp", Path: "/tmp"},
+}
+
+var mysqlWritableDirs = []writableDir{
+	{Name: "tmp", Path: "/tmp"},
+	{Name: "mysql-run", Path: "/var/run/mysqld"},
+	{Name: "mysql-files", Path: "/var/lib/mysql-files"},
+}
+
+// applySecurityContext hardens a pod to the restricted Pod Security Standard, unless the spec overrides the contexts.
+// The containers run as user, which also owns the mounted volumes
+func applySecurityContext(pod *corev1.PodSpec, podContext *corev1.PodSecurityContext, containerContext *corev1.SecurityContext, user int64) {
+	pod.SecurityContext = &corev1.PodSecurityContext{
+		RunAsNonRoot: ptr.To(true),
+		RunAsUser:    ptr.To(user),
+		RunAsGroup:   ptr.To(user),
+		FSGroup:      ptr.To(user),
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+	if podContext != nil {
+		pod.SecurityContext = podContext.DeepCopy()
+	}
+	for i := range pod.Containers {
+		pod.Containers[i].SecurityContext = containerSecurityContext(containerContext)
+	}
+}
+
+// secureOverlaidContainers gives the containers a pod template overlay added without a security context the one of
+// the rendered containers, so sidecars and init containers are held to the same standard
+func secureOverlaidContainers(pod *corev1.PodSpec, containerContext *corev1.SecurityContext) {
+	for i := range pod.InitContainers {
+		if pod.InitContainers[i].SecurityContext == nil {
+			pod.InitContainers[i].SecurityContext = containerSecurityContext(containerContext)
+		}
+	}
+	for i := range pod.Containers {
+		if pod.Containers[i].SecurityContext == nil {
+			pod.Containers[i].SecurityContext = containerSecurityContext(containerContext)
+		}
+	}
+}
+
+// containerSecurityContext is the configured container security context, or the restricted default
+func containerSecurityContext(containerContext *corev1.SecurityContext) *corev1.SecurityContext {
+	if containerContext != nil {
+		return containerContext.DeepCopy()
+	}
+	return &corev1.SecurityContext{
+		AllowPrivilegeEscalation: ptr.To(false),
+		ReadOnlyRootFilesystem:   ptr.To(true),
+		RunAsNonRoot:             ptr.To(true),
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+}
+
+// mountWritableDirs mounts an emptyDir over each of the dirs in every container of a pod
+func mountWritableDirs(pod *corev1.PodSpec, dirs []writableDir) {
+	for _, dir := range dirs {
+		pod.Volumes = append(pod.Volumes, corev1.Volume{
+			Name: dir.Name,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		})
+		for i := range pod.Containers {
+			pod.Containers[i].VolumeMounts = append(pod.Containers[i].VolumeMounts, corev1.VolumeMount{
+				Name:      dir.Name,
+				MountPath: dir.Path,
+			})
+		}
+	}
+}
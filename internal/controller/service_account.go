@@ -0,0 +1,125 @@
+package controller
+
+import (
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+	"github.com/bitcoin-sv/cars-operator/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// carsRules are what the cars app may do in its own namespace. The operator holds each of them itself,
+// as it cannot grant more than it is granted
+var carsRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{""},
+		Resources: []string{"persistentvolumeclaims", "configmaps", "secrets", "endpoints"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+	{
+		APIGroups: []string{"apps"},
+		Resources: []string{"deployments"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+	{
+		APIGroups: []string{"networking.k8s.io"},
+		Resources: []string{"ingresses"},
+		Verbs:     []string{"get", "list", "watch", "create", "update"},
+	},
+}
+
+// ReconcileServiceAccount is the cars ServiceAccount reconciler
+func (r *CarsReconciler) ReconcileServiceAccount(scope *reconcileScope) (utils.StepResult, error) {
+	return r.applyIdentity(scope, "ServiceAccount", renderCarsServiceAccount(scope.Cars))
+}
+
+// ReconcileRole is the cars Role reconciler
+func (r *CarsReconciler) ReconcileRole(scope *reconcileScope) (utils.StepResult, error) {
+	return r.applyIdentity(scope, "Role", renderCarsRole(scope.Cars))
+}
+
+// ReconcileRoleBinding is the cars RoleBinding reconciler
+func (r *CarsReconciler) ReconcileRoleBinding(scope *reconcileScope) (utils.StepResult, error) {
+	return r.applyIdentity(scope, "RoleBinding", renderCarsRoleBinding(scope.Cars))
+}
+
+func (r *CarsReconciler) applyIdentity(scope *reconcileScope, kind string, obj client.Object) (utils.StepResult, error) {
+	cars := scope.Cars
+	// Skip if an existing account is referenced, the objects rendered before are pruned
+	if !serviceAccountEnabled(cars) {
+		return utils.StepSkipped, nil
+	}
+	op, err := r.apply(scope, obj, func() error {
+		return controllerutil.SetControllerReference(cars, obj, r.Scheme)
+	})
+	r.recordResult(cars, kind, obj.GetName(), op, err)
+	if err != nil {
+		return utils.StepFailed, err
+	}
+	return utils.StepCompleted, nil
+}
+
+// serviceAccountEnabled returns whether the operator renders the account the cars pods run as
+func serviceAccountEnabled(cars *infrav1alpha1.Cars) bool {
+	return cars.Spec.ServiceAccountName == ""
+}
+
+// carsServiceAccountName is the account the cars pods run as
+func carsServiceAccountName(cars *infrav1alpha1.Cars) string {
+	if !serviceAccountEnabled(cars) {
+		return cars.Spec.ServiceAccountName
+	}
+	return carsName(cars)
+}
+
+// renderCarsServiceAccount renders the cars ServiceAccount, leaving the owner reference to the reconciler
+func renderCarsServiceAccount(cars *infrav1alpha1.Cars) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      carsServiceAccountName(cars),
+			Namespace: cars.Namespace,
+			Labels:    getAppLabels(cars),
+		},
+	}
+}
+
+// renderCarsRole renders the cars Role, leaving the owner reference to the reconciler
+func renderCarsRole(cars *infrav1alpha1.Cars) *rbacv1.Role {
+	rules := make([]rbacv1.PolicyRule, 0, len(carsRules))
+	for _, rule := range carsRules {
+		rules = append(rules, *rule.DeepCopy())
+	}
+	return &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      carsName(cars),
+			Namespace: cars.Namespace,
+			Labels:    getAppLabels(cars),
+		},
+		Rules: rules,
+	}
+}
+
+// renderCarsRoleBinding renders the cars RoleBinding, leaving the owner reference to the reconciler
+func renderCarsRoleBinding(cars *infrav1alpha1.Cars) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      carsName(cars),
+			Namespace: cars.Namespace,
+			Labels:    getAppLabels(cars),
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     carsName(cars),
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      carsServiceAccountName(cars),
+				Namespace: cars.Namespace,
+			},
+		},
+	}
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+)
+
+// ValidateSpec checks the parts of the spec the CRD schema cannot express. Nothing is rendered for an invalid spec
+func ValidateSpec(cars *infrav1alpha1.Cars) error {
+	spec := field.NewPath("spec")
+	errs := field.ErrorList{}
+	errs = append(errs, validateResources(cars.Spec.Resources, spec.Child("resources"))...)
+	errs = append(errs, validateResources(cars.Spec.Database.Resources, spec.Child("database", "resources"))...)
+	base := cars.DeepCopy()
+	base.Spec.PodTemplate, base.Spec.Database.PodTemplate = nil, nil
+	errs = append(errs, validatePodTemplate(renderCarsDeployment(base), cars.Spec.PodTemplate, spec.Child("podTemplate"))...)
+	errs = append(errs, validatePodTemplate(renderMysqlDeployment(base), cars.Spec.Database.PodTemplate,
+		spec.Child("database", "podTemplate"))...)
+	return errs.ToAggregate()
+}
+
+// validateResources rejects requests that exceed their limits, which the API server would reject on the pod template
+func validateResources(resources *corev1.ResourceRequirements, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if resources == nil {
+		return errs
+	}
+	names := make([]string, 0, len(resources.Requests))
+	for name := range resources.Requests {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		request := resources.Requests[corev1.ResourceName(name)]
+		limit, ok := resources.Limits[corev1.ResourceName(name)]
+		if ok && request.Cmp(limit) > 0 {
+			errs = append(errs, field.Invalid(path.Child("requests").Key(name), request.String(),
+				fmt.Sprintf("must be less than or equal to %s limit of %s", name, limit.String())))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	infrav1alpha1 "github.com/bitcoin-sv/cars-operator/api/v1alpha1"
+)
+
+func TestValidateSpecRejectsRequestsAboveLimits(t *testing.T) {
+	cars := &infrav1alpha1.Cars{}
+	cars.Spec.Resources = &corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+	}
+	cars.Spec.Database.Resources = &corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+	}
+	if err := ValidateSpec(cars); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cars.Spec.Database.Resources.Limits = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")}
+	err := ValidateSpec(cars)
+	if err == nil || !strings.Contains(err.Error(), "spec.database.resources.requests[cpu]") {
+		t.Fatalf("expected the database cpu request to be rejected, got %v", err)
+	}
+}
+
+func TestValidateSpecRejectsPodTemplatesBreakingTheDeployment(t *testing.T) {
+	cars := &infrav1alpha1.Cars{}
+	cars.Name = "validate"
+	cars.Spec.PodTemplate = &runtime.RawExtension{Raw: []byte(`{"metadata":{"labels":{"team":"cars"}},"spec":{"containers":[{"name":"log-forwarder","image":"fluent-bit"}]}}`)}
+	if err := ValidateSpec(cars); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for overlay, want := range map[string]string{
+		`{"metadata":{"labels":{"app.kubernetes.io/component":"other"}}}`: "spec.podTemplate.metadata.labels[app.kubernetes.io/component]",
+		`{"spec":{"containers":[{"name":"cars","$patch":"delete"}]}}`:     "spec.podTemplate.spec.containers",
+		`{"spec":{"containerz":[]}}`:                                      "spec.podTemplate",
+	} {
+		cars.Spec.PodTemplate = &runtime.RawExtension{Raw: []byte(overlay)}
+		if err := ValidateSpec(cars); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %s to be rejected at %s, got %v", overlay, want, err)
+		}
+	}
+
+	cars.Spec.PodTemplate = nil
+	cars.Spec.Database.PodTemplate = &runtime.RawExtension{Raw: []byte(`{"spec":{"containers":[{"name":"cars","$patch":"delete"}]}}`)}
+	if err := ValidateSpec(cars); err != nil {
+		t.Errorf("deleting a container the mysql pod does not have was rejected: %v", err)
+	}
+	cars.Spec.Database.PodTemplate = &runtime.RawExtension{Raw: []byte(`{"spec":{"$patch":"replace","containers":[{"name":"db","image":"mysql"}]}}`)}
+	if err := ValidateSpec(cars); err == nil || !strings.Contains(err.Error(), "spec.database.podTemplate.spec.containers") {
+		t.Errorf("expected replacing the mysql container to be rejected, got %v", err)
+	}
+}
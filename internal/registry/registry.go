@@ -0,0 +1,252 @@
+// Package registry resolves image tags to digests through the registry HTTP API
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// manifestTypes are the manifest media types accepted when resolving a tag, manifest lists first so multi-arch
+// images resolve to the digest of the list
+var manifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Resolver resolves an image reference to the digest of its manifest
+type Resolver interface {
+	Resolve(ctx context.Context, image string, pullSecrets []corev1.Secret) (string, error)
+}
+
+// DefaultTimeout bounds the resolution of a digest, so an unresponsive registry does not hold up the reconcile
+const DefaultTimeout = 30 * time.Second
+
+// HTTPResolver resolves digests with HEAD requests on the manifests endpoint of the registry API
+type HTTPResolver struct {
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+	// Timeout bounds the requests resolving a digest, DefaultTimeout when zero
+	Timeout time.Duration
+	// PlainHTTP talks to registries over http instead of https
+	PlainHTTP bool
+}
+
+// Reference is an image reference split into the registry host, repository and tag or digest
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference splits an image reference, applying the Docker Hub defaults for the registry, the library
+// namespace and the latest tag
+func ParseReference(image string) (Reference, error) {
+	ref := Reference{}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return Reference{}, fmt.Errorf("invalid image reference %q", image)
+	}
+	ref.Registry, ref.Repository = "docker.io", name
+	if i := strings.Index(name, "/"); i >= 0 {
+		host := name[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			ref.Registry, ref.Repository = host, name[i+1:]
+		}
+	}
+	if ref.Registry == "docker.io" && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+// Resolve returns the digest of the manifest an image reference points to. References that already carry a digest
+// are returned as is. Credentials for the registry are taken from the dockerconfigjson pull secrets
+func (r *HTTPResolver) Resolve(ctx context.Context, image string, pullSecrets []corev1.Secret) (string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	host := ref.Registry
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	scheme := "https"
+	if r.PlainHTTP {
+		scheme = "http"
+	}
+	manifestURL := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, host, ref.Repository, ref.Tag)
+	username, password := credentials(ref.Registry, pullSecrets)
+
+	resp, err := r.head(ctx, manifestURL, func(req *http.Request) {
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		// Registries like Docker Hub require a bearer token, even for anonymous pulls
+		token, err := r.token(ctx, resp.Header.Get("WWW-Authenticate"), username, password)
+		if err != nil {
+			return "", err
+		}
+		resp, err = r.head(ctx, manifestURL, func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+token)
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolving %s: registry returned %s", image, resp.Status)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("resolving %s: registry returned no digest", image)
+	}
+	return digest, nil
+}
+
+func (r *HTTPResolver) client() *http.Client {
+	if r.Client != nil {
+		return r.Client
+	}
+	return http.DefaultClient
+}
+
+func (r *HTTPResolver) head(ctx context.Context, manifestURL string, authorize func(*http.Request)) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+	authorize(req)
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// token fetches a bearer token from the realm of a WWW-Authenticate challenge
+func (r *HTTPResolver) token(ctx context.Context, challenge string, username string, password string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported registry authentication %q", challenge)
+	}
+	values := url.Values{}
+	realm := ""
+	for _, param := range strings.Split(params, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		value = strings.Trim(value, `"`)
+		if key == "realm" {
+			realm = value
+		} else if key != "" {
+			values.Set(key, value)
+		}
+	}
+	if realm == "" {
+		return "", fmt.Errorf("registry authentication challenge %q has no realm", challenge)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+values.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching registry token: %s", resp.Status)
+	}
+	body := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// credentials looks up the registry in the dockerconfigjson pull secrets
+func credentials(registry string, pullSecrets []corev1.Secret) (string, string) {
+	for _, secret := range pullSecrets {
+		if secret.Type != corev1.SecretTypeDockerConfigJson {
+			continue
+		}
+		config := struct {
+			Auths map[string]struct {
+				Username string `json:"username"`
+				Password string `json:"password"`
+				Auth     string `json:"auth"`
+			} `json:"auths"`
+		}{}
+		if err := json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &config); err != nil {
+			continue
+		}
+		for server, auth := range config.Auths {
+			if !matchesRegistry(server, registry) {
+				continue
+			}
+			if auth.Username != "" {
+				return auth.Username, auth.Password
+			}
+			if decoded, err := base64.StdEncoding.DecodeString(auth.Auth); err == nil {
+				username, password, _ := strings.Cut(string(decoded), ":")
+				return username, password
+			}
+		}
+	}
+	return "", ""
+}
+
+// matchesRegistry compares a dockerconfigjson server, which may be a URL, with a registry host
+func matchesRegistry(server string, registry string) bool {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	server, _, _ = strings.Cut(server, "/")
+	if registry == "docker.io" {
+		return server == "docker.io" || server == "index.docker.io" || server == "registry-1.docker.io"
+	}
+	return server == registry
+}
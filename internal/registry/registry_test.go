@@ -0,0 +1,96 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestParseReference(t *testing.T) {
+	for image, want := range map[string]Reference{
+		"mysql:8.0":                         {Registry: "docker.io", Repository: "library/mysql", Tag: "8.0"},
+		"docker.io/galtbv/cars":             {Registry: "docker.io", Repository: "galtbv/cars", Tag: "latest"},
+		"localhost:5000/cars:v1":            {Registry: "localhost:5000", Repository: "cars", Tag: "v1"},
+		"ghcr.io/org/cars@" + testDigest:    {Registry: "ghcr.io", Repository: "org/cars", Digest: testDigest},
+		"ghcr.io/org/cars:v1@" + testDigest: {Registry: "ghcr.io", Repository: "org/cars", Tag: "v1", Digest: testDigest},
+	} {
+		got, err := ParseReference(image)
+		if err != nil || got != want {
+			t.Errorf("ParseReference(%q) = %+v, %v, want %+v", image, got, err, want)
+		}
+	}
+}
+
+// registry is a stand-in for a registry serving a single manifest behind bearer token authentication
+func registry(t *testing.T, repository string, tag string) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/token":
+			if user, password, _ := req.BasicAuth(); user != "robot" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"token":"registry-token"}`)
+		case req.Header.Get("Authorization") != "Bearer registry-token":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:%s:pull"`, server.URL, repository))
+			w.WriteHeader(http.StatusUnauthorized)
+		case req.Method == http.MethodHead && req.URL.Path == fmt.Sprintf("/v2/%s/manifests/%s", repository, tag):
+			if !strings.Contains(req.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json") {
+				t.Errorf("manifest lists are not accepted: %s", req.Header.Get("Accept"))
+			}
+			w.Header().Set("Docker-Content-Digest", testDigest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestResolveWithPullSecret(t *testing.T) {
+	server := registry(t, "galtbv/cars", "v1")
+	host := strings.TrimPrefix(server.URL, "https://")
+	pullSecret := corev1.Secret{
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(fmt.Sprintf(`{"auths":{"%s":{"auth":"cm9ib3Q6c2VjcmV0"}}}`, host)),
+		},
+	}
+	resolver := &HTTPResolver{Client: server.Client()}
+
+	digest, err := resolver.Resolve(context.Background(), host+"/galtbv/cars:v1", []corev1.Secret{pullSecret})
+	if err != nil || digest != testDigest {
+		t.Fatalf("Resolve = %q, %v, want %s", digest, err, testDigest)
+	}
+	if _, err := resolver.Resolve(context.Background(), host+"/galtbv/cars:v1", nil); err == nil {
+		t.Error("expected resolving without credentials to fail")
+	}
+	if _, err := resolver.Resolve(context.Background(), host+"/galtbv/cars:v2", []corev1.Secret{pullSecret}); err == nil {
+		t.Error("expected resolving an unknown tag to fail")
+	}
+}
+
+func TestResolveTimesOut(t *testing.T) {
+	hang := make(chan struct{})
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-hang
+	}))
+	defer server.Close()
+	defer close(hang)
+
+	resolver := &HTTPResolver{Client: server.Client(), Timeout: 50 * time.Millisecond}
+	image := strings.TrimPrefix(server.URL, "https://") + "/cars:v1"
+	if _, err := resolver.Resolve(context.Background(), image, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Resolve of an unresponsive registry = %v, want the deadline to be exceeded", err)
+	}
+}
@@ -0,0 +1,147 @@
+// Package utils implements utility functions
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// StepResult is the outcome of a reconcile step
+type StepResult string
+
+const (
+	// StepCompleted means the step reconciled its object; dependent steps run
+	StepCompleted StepResult = "Completed"
+	// StepSkipped means the step had nothing to do, e.g. an optional feature is off; dependent steps still run
+	StepSkipped StepResult = "Skipped"
+	// StepStopped means the step cannot complete yet; dependent steps do not run
+	StepStopped StepResult = "Stopped"
+	// StepFailed means the step returned an error; dependent steps do not run
+	StepFailed StepResult = "Failed"
+	// StepBlocked means the step did not run because a dependency stopped, failed or was blocked
+	StepBlocked StepResult = "Blocked"
+)
+
+// StepFunc is a reconcile step operating on a per-request scope
+type StepFunc[S any] func(S) (StepResult, error)
+
+// Step is a named reconcile step and the names of the steps it depends on
+type Step[S any] struct {
+	Name      string
+	DependsOn []string
+	Run       StepFunc[S]
+}
+
+// StepOutcome is the result of running a single step
+type StepOutcome struct {
+	Name   string
+	Result StepResult
+	Err    error
+}
+
+// ReconcileSteps runs the steps in dependency order. Steps whose dependencies have all completed or been skipped
+// run in parallel; a step is blocked when any of its dependencies stopped, failed or was blocked.
+// The outcomes are returned in the order the steps were given, together with all step errors joined
+func ReconcileSteps[S any](scope S, steps ...Step[S]) ([]StepOutcome, error) {
+	if err := validateSteps(steps); err != nil {
+		return nil, err
+	}
+
+	outcomes := make([]StepOutcome, len(steps))
+	done := make(map[string]chan struct{}, len(steps))
+	index := make(map[string]int, len(steps))
+	for i, step := range steps {
+		done[step.Name] = make(chan struct{})
+		index[step.Name] = i
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, step := range steps {
+		wg.Add(1)
+		go func(i int, step Step[S]) {
+			defer wg.Done()
+			defer close(done[step.Name])
+
+			blocked := false
+			for _, dependency := range step.DependsOn {
+				<-done[dependency]
+				mu.Lock()
+				result := outcomes[index[dependency]].Result
+				mu.Unlock()
+				if result != StepCompleted && result != StepSkipped {
+					blocked = true
+				}
+			}
+
+			outcome := StepOutcome{Name: step.Name, Result: StepBlocked}
+			if !blocked {
+				outcome.Result, outcome.Err = step.Run(scope)
+				if outcome.Err != nil {
+					outcome.Result = StepFailed
+				}
+			}
+			mu.Lock()
+			outcomes[i] = outcome
+			mu.Unlock()
+		}(i, step)
+	}
+	wg.Wait()
+
+	errs := []error{}
+	for _, outcome := range outcomes {
+		if outcome.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", outcome.Name, outcome.Err))
+		}
+	}
+	return outcomes, errors.Join(errs...)
+}
+
+// validateSteps rejects duplicate names, unknown dependencies and dependency cycles, which would deadlock the run
+func validateSteps[S any](steps []Step[S]) error {
+	dependencies := make(map[string][]string, len(steps))
+	for _, step := range steps {
+		if _, ok := dependencies[step.Name]; ok {
+			return fmt.Errorf("duplicate reconcile step %q", step.Name)
+		}
+		dependencies[step.Name] = step.DependsOn
+	}
+	for _, step := range steps {
+		for _, dependency := range step.DependsOn {
+			if _, ok := dependencies[dependency]; !ok {
+				return fmt.Errorf("reconcile step %q depends on unknown step %q", step.Name, dependency)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(steps))
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("reconcile step %q is part of a dependency cycle", name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dependency := range dependencies[name] {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, step := range steps {
+		if err := visit(step.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
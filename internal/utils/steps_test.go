@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+type recorder struct {
+	mu  sync.Mutex
+	ran []string
+}
+
+func (r *recorder) step(name string, result StepResult, err error) Step[*recorder] {
+	return Step[*recorder]{
+		Name: name,
+		Run: func(r *recorder) (StepResult, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.ran = append(r.ran, name)
+			return result, err
+		},
+	}
+}
+
+func (r *recorder) index(name string) int {
+	for i, ran := range r.ran {
+		if ran == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func after(step Step[*recorder], dependencies ...string) Step[*recorder] {
+	step.DependsOn = dependencies
+	return step
+}
+
+func TestReconcileStepsOrdersDependencies(t *testing.T) {
+	r := &recorder{}
+	outcomes, err := ReconcileSteps(r,
+		after(r.step("app", StepCompleted, nil), "service"),
+		after(r.step("service", StepCompleted, nil), "database"),
+		r.step("database", StepCompleted, nil),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !(r.index("database") < r.index("service") && r.index("service") < r.index("app")) {
+		t.Fatalf("steps ran out of dependency order: %v", r.ran)
+	}
+	for i, name := range []string{"app", "service", "database"} {
+		if outcomes[i].Name != name || outcomes[i].Result != StepCompleted {
+			t.Errorf("outcome %d = %+v, want %s completed", i, outcomes[i], name)
+		}
+	}
+}
+
+func TestReconcileStepsRunsDependentsOfSkippedSteps(t *testing.T) {
+	r := &recorder{}
+	outcomes, err := ReconcileSteps(r,
+		r.step("ingress", StepSkipped, nil),
+		after(r.step("dns", StepCompleted, nil), "ingress"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if outcomes[0].Result != StepSkipped || outcomes[1].Result != StepCompleted {
+		t.Fatalf("unexpected outcomes: %+v", outcomes)
+	}
+}
+
+func TestReconcileStepsBlocksDependentsAndAggregatesErrors(t *testing.T) {
+	r := &recorder{}
+	outcomes, err := ReconcileSteps(r,
+		r.step("pvc", StepStopped, nil),
+		after(r.step("database", StepCompleted, nil), "pvc"),
+		after(r.step("app", StepCompleted, nil), "database"),
+		r.step("service", StepCompleted, errors.New("service failed")),
+		r.step("ingress", StepCompleted, errors.New("ingress failed")),
+	)
+	want := []StepResult{StepStopped, StepBlocked, StepBlocked, StepFailed, StepFailed}
+	for i, result := range want {
+		if outcomes[i].Result != result {
+			t.Errorf("outcome %s = %s, want %s", outcomes[i].Name, outcomes[i].Result, result)
+		}
+	}
+	if r.index("database") != -1 || r.index("app") != -1 {
+		t.Errorf("blocked steps ran: %v", r.ran)
+	}
+	if err == nil || err.Error() != "service: service failed\ningress: ingress failed" {
+		t.Errorf("unexpected aggregated error: %v", err)
+	}
+}
+
+func TestReconcileStepsRejectsInvalidGraphs(t *testing.T) {
+	r := &recorder{}
+	if _, err := ReconcileSteps(r, after(r.step("a", StepCompleted, nil), "missing")); err == nil {
+		t.Error("expected an error for an unknown dependency")
+	}
+	if _, err := ReconcileSteps(r, r.step("a", StepCompleted, nil), r.step("a", StepCompleted, nil)); err == nil {
+		t.Error("expected an error for duplicate steps")
+	}
+	if _, err := ReconcileSteps(r,
+		after(r.step("a", StepCompleted, nil), "b"),
+		after(r.step("b", StepCompleted, nil), "a"),
+	); err == nil {
+		t.Error("expected an error for a dependency cycle")
+	}
+	if len(r.ran) != 0 {
+		t.Errorf("steps ran for an invalid graph: %v", r.ran)
+	}
+}